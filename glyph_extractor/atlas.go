@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+)
+
+// AtlasJSON describes the packed atlas pages and where every glyph landed
+type AtlasJSON struct {
+	Version  int                    `json:"version"`
+	CellSize manifest.CellSize      `json:"cellSize"`
+	DPI      int                    `json:"dpi,omitempty"`
+	Padding  int                    `json:"padding"`
+	Pages    []AtlasPage            `json:"pages"`
+	Frames   map[string]AtlasFrame  `json:"frames"`
+	Kerning  []manifest.KerningPair `json:"kerning,omitempty"`
+}
+
+type AtlasPage struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// AtlasFrame locates one glyph inside an atlas page.
+// Offset is the position of the packed (trimmed) frame inside the original glyph
+// image, so Offset + Frame size never exceeds SourceSize.
+type AtlasFrame struct {
+	File       string                `json:"file"`
+	Page       int                   `json:"page"`
+	Frame      AtlasRect             `json:"frame"`
+	Offset     AtlasPoint            `json:"offset"`
+	SourceSize AtlasSize             `json:"sourceSize"`
+	Metrics    manifest.GlyphMetrics `json:"metrics"`
+}
+
+type AtlasRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type AtlasPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type AtlasSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// AtlasConfig holds the packing options
+type AtlasConfig struct {
+	Padding    int  // Transparent pixels between frames and around page edges
+	MaxSize    int  // Maximum page width and height
+	PowerOfTwo bool // Round page size up to a power of two
+	TrimAlpha  bool // Strip fully transparent borders before packing
+}
+
+// DefaultAtlasConfig returns the default atlas packing options
+func DefaultAtlasConfig() AtlasConfig {
+	return AtlasConfig{
+		Padding:    2,
+		MaxSize:    2048,
+		PowerOfTwo: true,
+		TrimAlpha:  true,
+	}
+}
+
+// Atlas is a packed glyph set ready to be written to disk
+type Atlas struct {
+	Pages  []*image.NRGBA
+	Frames map[string]AtlasFrame
+	Keys   []string // Glyph keys in charset order
+}
+
+// sprite is a glyph image waiting to be packed
+type sprite struct {
+	key    string
+	glyph  *manifest.Glyph
+	bounds image.Rectangle // Trimmed area in glyph image coordinates
+}
+
+// maxRectsBin is a MaxRects bin packer using the best-short-side-fit heuristic
+type maxRectsBin struct {
+	width, height int
+	free          []image.Rectangle
+	usedMax       image.Point
+}
+
+func newMaxRectsBin(width, height int) *maxRectsBin {
+	return &maxRectsBin{
+		width:  width,
+		height: height,
+		free:   []image.Rectangle{image.Rect(0, 0, width, height)},
+	}
+}
+
+// insert places a w×h rectangle and returns its position, or false if it doesn't fit
+func (b *maxRectsBin) insert(w, h int) (image.Rectangle, bool) {
+	best := image.Rectangle{}
+	bestShort, bestLong := -1, -1
+
+	for _, f := range b.free {
+		if f.Dx() < w || f.Dy() < h {
+			continue
+		}
+		leftoverX := f.Dx() - w
+		leftoverY := f.Dy() - h
+		short := min(leftoverX, leftoverY)
+		long := max(leftoverX, leftoverY)
+		if bestShort < 0 || short < bestShort || (short == bestShort && long < bestLong) {
+			best = image.Rect(f.Min.X, f.Min.Y, f.Min.X+w, f.Min.Y+h)
+			bestShort, bestLong = short, long
+		}
+	}
+
+	if bestShort < 0 {
+		return image.Rectangle{}, false
+	}
+
+	b.place(best)
+	return best, true
+}
+
+// place splits every free rectangle overlapping r and prunes redundant ones
+func (b *maxRectsBin) place(r image.Rectangle) {
+	var next []image.Rectangle
+	for _, f := range b.free {
+		if !f.Overlaps(r) {
+			next = append(next, f)
+			continue
+		}
+		if r.Min.X > f.Min.X {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, r.Min.X, f.Max.Y))
+		}
+		if r.Max.X < f.Max.X {
+			next = append(next, image.Rect(r.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if r.Min.Y > f.Min.Y {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, f.Max.X, r.Min.Y))
+		}
+		if r.Max.Y < f.Max.Y {
+			next = append(next, image.Rect(f.Min.X, r.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	// Drop free rectangles fully contained in another one
+	b.free = b.free[:0]
+	for i, a := range next {
+		contained := false
+		for j, c := range next {
+			if i != j && a.In(c) && (a != c || i > j) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			b.free = append(b.free, a)
+		}
+	}
+
+	b.usedMax.X = max(b.usedMax.X, r.Max.X)
+	b.usedMax.Y = max(b.usedMax.Y, r.Max.Y)
+}
+
+// alphaBounds returns the smallest rectangle containing all non-transparent pixels
+func alphaBounds(img image.Image) image.Rectangle {
+	bounds := img.Bounds()
+	minX, minY := bounds.Max.X, bounds.Max.Y
+	maxX, maxY := bounds.Min.X, bounds.Min.Y
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			minX = min(minX, x)
+			minY = min(minY, y)
+			maxX = max(maxX, x+1)
+			maxY = max(maxY, y+1)
+		}
+	}
+
+	if minX >= maxX || minY >= maxY {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// nextPowerOfTwo rounds n up to the nearest power of two
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// prevPowerOfTwo rounds n down to the nearest power of two
+func prevPowerOfTwo(n int) int {
+	p := 1
+	for p*2 <= n {
+		p <<= 1
+	}
+	return p
+}
+
+// PackAtlas packs every glyph of the set into one or more atlas pages
+func PackAtlas(set *manifest.GlyphSet, config AtlasConfig) (*Atlas, error) {
+	keys := set.Keys()
+
+	// Rounding up must never push a page past the configured maximum
+	if config.PowerOfTwo {
+		config.MaxSize = prevPowerOfTwo(config.MaxSize)
+	}
+
+	sprites := make([]sprite, 0, len(keys))
+	for _, key := range keys {
+		glyph := set.Glyphs[key]
+		bounds := glyph.Image.Bounds()
+		if config.TrimAlpha {
+			bounds = alphaBounds(glyph.Image)
+		}
+		if bounds.Dx()+config.Padding > config.MaxSize-config.Padding ||
+			bounds.Dy()+config.Padding > config.MaxSize-config.Padding {
+			return nil, fmt.Errorf("glyph '%s' (%dx%d) does not fit into %dpx atlas page",
+				key, bounds.Dx(), bounds.Dy(), config.MaxSize)
+		}
+		sprites = append(sprites, sprite{key: key, glyph: glyph, bounds: bounds})
+	}
+
+	// Tallest first packs noticeably tighter for glyph-shaped rectangles
+	order := make([]int, len(sprites))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := sprites[order[a]].bounds, sprites[order[b]].bounds
+		if sa.Dy() != sb.Dy() {
+			return sa.Dy() > sb.Dy()
+		}
+		return sa.Dx() > sb.Dx()
+	})
+
+	type placement struct {
+		page int
+		rect image.Rectangle
+	}
+	placements := make(map[int]placement)
+
+	var bins []*maxRectsBin
+	pending := order
+	for len(pending) > 0 {
+		// The bin leaves room for padding on the top/left page edges
+		bin := newMaxRectsBin(config.MaxSize-config.Padding, config.MaxSize-config.Padding)
+		pageIndex := len(bins)
+		bins = append(bins, bin)
+
+		var leftover []int
+		for _, i := range pending {
+			s := sprites[i]
+			if s.bounds.Empty() {
+				placements[i] = placement{page: pageIndex}
+				continue
+			}
+			r, ok := bin.insert(s.bounds.Dx()+config.Padding, s.bounds.Dy()+config.Padding)
+			if !ok {
+				leftover = append(leftover, i)
+				continue
+			}
+			frame := image.Rect(r.Min.X+config.Padding, r.Min.Y+config.Padding,
+				r.Min.X+config.Padding+s.bounds.Dx(), r.Min.Y+config.Padding+s.bounds.Dy())
+			placements[i] = placement{page: pageIndex, rect: frame}
+		}
+		pending = leftover
+	}
+
+	// All pages share one size so consumers can treat them uniformly
+	pageW, pageH := 1, 1
+	for _, bin := range bins {
+		pageW = max(pageW, bin.usedMax.X+config.Padding)
+		pageH = max(pageH, bin.usedMax.Y+config.Padding)
+	}
+	if config.PowerOfTwo {
+		pageW = nextPowerOfTwo(pageW)
+		pageH = nextPowerOfTwo(pageH)
+	}
+
+	atlas := &Atlas{
+		Frames: make(map[string]AtlasFrame),
+		Keys:   keys,
+	}
+	for range bins {
+		atlas.Pages = append(atlas.Pages, image.NewNRGBA(image.Rect(0, 0, pageW, pageH)))
+	}
+
+	for i, s := range sprites {
+		p := placements[i]
+		if !p.rect.Empty() {
+			draw.Draw(atlas.Pages[p.page], p.rect, s.glyph.Image, s.bounds.Min, draw.Src)
+		}
+
+		src := s.glyph.Image.Bounds()
+		atlas.Frames[s.key] = AtlasFrame{
+			File:       s.glyph.File,
+			Page:       p.page,
+			Frame:      AtlasRect{X: p.rect.Min.X, Y: p.rect.Min.Y, W: p.rect.Dx(), H: p.rect.Dy()},
+			Offset:     AtlasPoint{X: s.bounds.Min.X - src.Min.X, Y: s.bounds.Min.Y - src.Min.Y},
+			SourceSize: AtlasSize{W: src.Dx(), H: src.Dy()},
+			Metrics:    s.glyph.Metrics,
+		}
+	}
+
+	return atlas, nil
+}
+
+// pageFilename returns the file name of an atlas page
+func pageFilename(name string, page int) string {
+	return fmt.Sprintf("%s_%d.png", name, page)
+}
+
+// writeAtlasPages saves the atlas page PNGs into outputDir
+func writeAtlasPages(atlas *Atlas, outputDir, name string) ([]AtlasPage, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	var pages []AtlasPage
+	for i, page := range atlas.Pages {
+		filename := pageFilename(name, i)
+		if err := imaging.SavePNG(page, filepath.Join(outputDir, filename)); err != nil {
+			return nil, fmt.Errorf("saving %s: %w", filename, err)
+		}
+		pages = append(pages, AtlasPage{
+			File:   filename,
+			Width:  page.Bounds().Dx(),
+			Height: page.Bounds().Dy(),
+		})
+	}
+	return pages, nil
+}
+
+// writeAtlas saves the atlas pages and the JSON frame data into outputDir
+func writeAtlas(atlas *Atlas, set *manifest.GlyphSet, config AtlasConfig, outputDir, name string) (string, error) {
+	pages, err := writeAtlasPages(atlas, outputDir, name)
+	if err != nil {
+		return "", err
+	}
+
+	output := AtlasJSON{
+		Version:  1,
+		CellSize: set.Manifest.CellSize,
+		DPI:      set.Manifest.DPI,
+		Padding:  config.Padding,
+		Pages:    pages,
+		Frames:   atlas.Frames,
+		Kerning:  set.KerningPairs(),
+	}
+
+	jsonData, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("creating JSON: %w", err)
+	}
+
+	jsonPath := filepath.Join(outputDir, name+".json")
+	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
+		return "", fmt.Errorf("writing JSON: %w", err)
+	}
+	return jsonPath, nil
+}
+
+// addAtlasFlags registers the packing options shared by atlas-based exporters
+func addAtlasFlags(fs *flag.FlagSet, config *AtlasConfig) {
+	fs.IntVar(&config.Padding, "padding", config.Padding, "Padding between glyphs in pixels")
+	fs.IntVar(&config.MaxSize, "max-size", config.MaxSize, "Maximum atlas page width/height in pixels")
+	fs.BoolVar(&config.PowerOfTwo, "pot", config.PowerOfTwo, "Round page size up to a power of two")
+	fs.BoolVar(&config.TrimAlpha, "trim", config.TrimAlpha, "Trim transparent borders before packing")
+}
+
+// loadAndPack loads a glyph set and packs it into atlas pages
+func loadAndPack(path string, config AtlasConfig) (*manifest.GlyphSet, *Atlas, error) {
+	if config.Padding < 0 || config.MaxSize <= 2*config.Padding {
+		return nil, nil, fmt.Errorf("invalid padding %d for max size %d", config.Padding, config.MaxSize)
+	}
+
+	set, err := manifest.LoadGlyphSet(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(set.Glyphs) == 0 {
+		return nil, nil, fmt.Errorf("no glyphs in %s", path)
+	}
+
+	fmt.Printf("Packing %d glyphs (max %dpx, padding %dpx)\n", len(set.Glyphs), config.MaxSize, config.Padding)
+
+	atlas, err := PackAtlas(set, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return set, atlas, nil
+}
+
+// runAtlas implements the atlas subcommand
+func runAtlas(args []string) error {
+	config := DefaultAtlasConfig()
+	var outputDir string
+	var name string
+
+	fs := flag.NewFlagSet("atlas", flag.ExitOnError)
+	fs.StringVar(&outputDir, "output", "", "Output directory (default atlas next to glyphs.json)")
+	fs.StringVar(&name, "name", "atlas", "Base name for page PNGs and the JSON file")
+	addAtlasFlags(fs, &config)
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor atlas [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if outputDir == "" {
+		outputDir = filepath.Join(filepath.Dir(manifestPath), "atlas")
+	}
+
+	set, atlas, err := loadAndPack(manifestPath, config)
+	if err != nil {
+		return err
+	}
+
+	jsonPath, err := writeAtlas(atlas, set, config, outputDir, name)
+	if err != nil {
+		return err
+	}
+
+	page := atlas.Pages[0].Bounds()
+	fmt.Printf("Done! %d page(s) of %dx%d pixels\n", len(atlas.Pages), page.Dx(), page.Dy())
+	fmt.Printf("Frame data: %s\n", jsonPath)
+	return nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/extract"
+	"glyph_extractor/scan"
+	"glyph_extractor/template"
+)
+
+// scanExtensions are the file types batch picks up as scans
+var scanExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true,
+	".bmp": true, ".webp": true, ".pdf": true,
+}
+
+// pageCode matches scan names that carry the writer and the page number,
+// e.g. novak_p1.jpg, novak-2.png or novak_strana3.tif
+var pageCode = regexp.MustCompile(`(?i)^(.+?)[_-](?:p|page|strana)?(\d+)$`)
+
+// batchWriter is one writer's scans in page order
+type batchWriter struct {
+	Name  string
+	Scans []string
+	Err   error // Problem found while grouping the scans
+}
+
+// BatchWriterReport is the outcome of extracting one writer's glyph set
+type BatchWriterReport struct {
+	Writer     string   `json:"writer"`
+	Output     string   `json:"output"`
+	Scans      []string `json:"scans"`
+	Pages      int      `json:"pages"`
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+	Glyphs     int      `json:"glyphs"`
+	Missing    []string `json:"missing,omitempty"`    // Charset entries without a glyph, e.g. blank cells
+	Suspicious []string `json:"suspicious,omitempty"` // Glyphs with warnings or an odd stroke width
+}
+
+// BatchReport summarizes a batch run; written as batch.json
+type BatchReport struct {
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Writers   []BatchWriterReport `json:"writers"`
+}
+
+// runBatch extracts a glyph set for every writer found in a directory of scans
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	outputDir := fs.String("output", "./batch_output", "Output directory; each writer gets a subdirectory")
+	extractFlags := addExtractFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor batch [options] <scans_dir>")
+		fmt.Println("\nThe scans directory holds one subdirectory per writer, or scans named")
+		fmt.Println("<writer>_p<page> (e.g. novak_p1.jpg, novak_p2.jpg). Any other scan file")
+		fmt.Println("is a writer of its own, e.g. a multi-page PDF.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts, rotation, err := extractFlags.options()
+	if err != nil {
+		return err
+	}
+	writers, err := findBatchWriters(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(writers) == 0 {
+		return fmt.Errorf("no scans found in %s", fs.Arg(0))
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return err
+	}
+
+	// Ctrl+C stops after the current writer's cells
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var report BatchReport
+	for i, w := range writers {
+		if ctx.Err() != nil {
+			break
+		}
+		r := extractWriter(ctx, w, *outputDir, extractFlags, opts, rotation)
+		report.Writers = append(report.Writers, r)
+		if r.OK {
+			report.Succeeded++
+			fmt.Printf("[%d/%d] %s: %d glyphs, %d missing, %d suspicious\n",
+				i+1, len(writers), w.Name, r.Glyphs, len(r.Missing), len(r.Suspicious))
+		} else {
+			report.Failed++
+			fmt.Printf("[%d/%d] %s: FAILED: %s\n", i+1, len(writers), w.Name, r.Error)
+		}
+	}
+
+	reportPath := filepath.Join(*outputDir, "batch.json")
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d of %d writers extracted, report: %s\n", report.Succeeded, len(writers), reportPath)
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted after %d writers", len(report.Writers))
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d writers failed", report.Failed, len(writers))
+	}
+	return nil
+}
+
+// extractWriter runs the extraction for one writer into outputRoot/<writer>.
+// Errors, and panics from a broken scan, end up in the report instead of
+// stopping the batch: the cell workers turn their panics into errors and
+// this goroutine recovers from its own.
+func extractWriter(ctx context.Context, w batchWriter, outputRoot string, flags *extractFlags, opts extract.Options, rotation int) (report BatchWriterReport) {
+	report = BatchWriterReport{
+		Writer: w.Name,
+		Output: filepath.Join(outputRoot, w.Name),
+		Scans:  w.Scans,
+	}
+	fail := func(err error) BatchWriterReport {
+		report.Error = err.Error()
+		return report
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			report.OK = false
+			report.Error = fmt.Sprintf("panic: %v", p)
+		}
+	}()
+
+	if w.Err != nil {
+		return fail(w.Err)
+	}
+	if err := os.MkdirAll(report.Output, 0755); err != nil {
+		return fail(err)
+	}
+
+	// The full extraction log of each writer goes next to its glyphs
+	logFile, err := os.Create(filepath.Join(report.Output, "extract.log"))
+	if err != nil {
+		return fail(err)
+	}
+	defer logFile.Close()
+
+	pages, err := scan.LoadAll(w.Scans, rotation)
+	if err != nil {
+		return fail(err)
+	}
+	report.Pages = len(pages)
+
+	opts.OutputDir = report.Output
+	opts.Log = logFile
+	config := flags.gridConfig(pages, template.DefaultConfig(), logFile)
+	set, err := extractPages(ctx, pages, config, opts)
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeExtraction(set, report.Output); err != nil {
+		return fail(err)
+	}
+	if flags.debug {
+		if err := writeDebugOverlays(pages, set.Cells, config, report.Output, logFile); err != nil {
+			return fail(err)
+		}
+	}
+
+	report.OK = true
+	report.Glyphs = len(set.Glyphs)
+	for _, char := range charset.Charset {
+		if _, ok := set.Glyphs[char]; !ok {
+			report.Missing = append(report.Missing, char)
+		}
+	}
+	suspicious := make(map[string]bool)
+	for key := range set.Manifest.Warnings {
+		suspicious[key] = true
+	}
+	if set.Manifest.Stroke != nil {
+		for _, key := range set.Manifest.Stroke.Outliers {
+			suspicious[key] = true
+		}
+	}
+	for _, char := range set.Keys() {
+		if suspicious[char] {
+			report.Suspicious = append(report.Suspicious, char)
+		}
+	}
+
+	if err := flags.check(set); err != nil {
+		report.OK = false
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// findBatchWriters groups the scans under root by writer: each subdirectory
+// is a writer, and scans directly in root are grouped by their page code
+func findBatchWriters(root string) ([]batchWriter, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var writers []batchWriter
+	coded := make(map[string]*batchWriter)
+	pageNumbers := make(map[string]map[int]string)
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		if entry.IsDir() {
+			scans, err := findScans(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(scans) > 0 {
+				writers = append(writers, batchWriter{Name: entry.Name(), Scans: scans})
+			}
+			continue
+		}
+		if !isScan(entry.Name()) {
+			continue
+		}
+
+		stem := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		m := pageCode.FindStringSubmatch(stem)
+		if m == nil {
+			writers = append(writers, batchWriter{Name: stem, Scans: []string{path}})
+			continue
+		}
+
+		name := m[1]
+		page, _ := strconv.Atoi(m[2])
+		w := coded[name]
+		if w == nil {
+			w = &batchWriter{Name: name}
+			coded[name] = w
+			pageNumbers[name] = make(map[int]string)
+		}
+		if other, ok := pageNumbers[name][page]; ok && w.Err == nil {
+			w.Err = fmt.Errorf("page %d scanned twice: %s and %s", page, other, entry.Name())
+		}
+		pageNumbers[name][page] = entry.Name()
+		w.Scans = append(w.Scans, path)
+	}
+
+	for _, w := range coded {
+		sortScans(w.Scans)
+		writers = append(writers, *w)
+	}
+
+	// A writer may have a directory or coded scans, not both
+	sort.SliceStable(writers, func(i, j int) bool { return writers[i].Name < writers[j].Name })
+	for i := 1; i < len(writers); i++ {
+		if writers[i].Name == writers[i-1].Name {
+			return nil, fmt.Errorf("writer %q has both a directory and scans in %s", writers[i].Name, root)
+		}
+	}
+	return writers, nil
+}
+
+// findScans lists the scans anywhere below dir in page order
+func findScans(dir string) ([]string, error) {
+	var scans []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isScan(path) {
+			scans = append(scans, path)
+		}
+		return nil
+	})
+	sortScans(scans)
+	return scans, err
+}
+
+// isScan reports whether a file name has a scan extension
+func isScan(name string) bool {
+	return scanExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// trailingNumber is the page number at the end of a scan name, e.g. scan_10
+var trailingNumber = regexp.MustCompile(`(\d+)$`)
+
+// sortScans orders scans by the number their name ends in, then by name, so
+// page10 follows page9
+func sortScans(scans []string) {
+	page := func(path string) int {
+		stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if m := trailingNumber.FindString(stem); m != "" {
+			n, _ := strconv.Atoi(m)
+			return n
+		}
+		return -1
+	}
+	sort.SliceStable(scans, func(i, j int) bool {
+		pi, pj := page(scans[i]), page(scans[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return scans[i] < scans[j]
+	})
+}
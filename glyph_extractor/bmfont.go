@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"glyph_extractor/manifest"
+)
+
+// BMFont is an AngelCode bitmap font description (format version 3)
+type BMFont struct {
+	Info     BMFontInfo
+	Common   BMFontCommon
+	Pages    []string
+	Chars    []BMFontChar
+	Kernings []BMFontKerning
+}
+
+type BMFontInfo struct {
+	Face     string
+	Size     int
+	Bold     bool
+	Italic   bool
+	Unicode  bool
+	Smooth   bool
+	StretchH int
+	AA       int
+	Padding  [4]int // up, right, down, left
+	Spacing  [2]int // horizontal, vertical
+	Outline  int
+}
+
+type BMFontCommon struct {
+	LineHeight int
+	Base       int
+	ScaleW     int
+	ScaleH     int
+	Packed     bool
+	AlphaChnl  int
+	RedChnl    int
+	GreenChnl  int
+	BlueChnl   int
+}
+
+type BMFontChar struct {
+	ID       rune
+	X        int
+	Y        int
+	Width    int
+	Height   int
+	XOffset  int
+	YOffset  int
+	XAdvance int
+	Page     int
+	Chnl     int
+}
+
+type BMFontKerning struct {
+	First  rune
+	Second rune
+	Amount int
+}
+
+// bmfontAllChannels marks a glyph as present in every channel of its page
+const bmfontAllChannels = 15
+
+// NewBMFont builds a BMFont description from a packed atlas.
+// The line box starts at the top of the template cell; base is the cell baseline.
+func NewBMFont(set *manifest.GlyphSet, atlas *Atlas, config AtlasConfig, face string, pages []AtlasPage) *BMFont {
+	lineHeight, base := set.LineBox()
+
+	font := &BMFont{
+		Info: BMFontInfo{
+			Face:     face,
+			Size:     lineHeight,
+			Unicode:  true,
+			Smooth:   true,
+			StretchH: 100,
+			AA:       1,
+			Spacing:  [2]int{config.Padding, config.Padding},
+		},
+		Common: BMFontCommon{
+			LineHeight: lineHeight,
+			Base:       base,
+		},
+	}
+
+	for _, page := range pages {
+		font.Pages = append(font.Pages, page.File)
+		font.Common.ScaleW = page.Width
+		font.Common.ScaleH = page.Height
+	}
+
+	for _, key := range atlas.Keys {
+		// BMFont addresses glyphs by a single code point
+		if utf8.RuneCountInString(key) != 1 {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(key)
+		frame := atlas.Frames[key]
+
+		font.Chars = append(font.Chars, BMFontChar{
+			ID:       r,
+			X:        frame.Frame.X,
+			Y:        frame.Frame.Y,
+			Width:    frame.Frame.W,
+			Height:   frame.Frame.H,
+			XOffset:  frame.Offset.X,
+			YOffset:  base - frame.Metrics.Baseline + frame.Offset.Y,
+			XAdvance: frame.SourceSize.W,
+			Page:     frame.Page,
+			Chnl:     bmfontAllChannels,
+		})
+	}
+	sort.Slice(font.Chars, func(i, j int) bool { return font.Chars[i].ID < font.Chars[j].ID })
+
+	for _, pair := range set.KerningPairs() {
+		if utf8.RuneCountInString(pair.Left) != 1 || utf8.RuneCountInString(pair.Right) != 1 {
+			continue
+		}
+		first, _ := utf8.DecodeRuneInString(pair.Left)
+		second, _ := utf8.DecodeRuneInString(pair.Right)
+		font.Kernings = append(font.Kernings, BMFontKerning{First: first, Second: second, Amount: pair.Amount})
+	}
+
+	return font
+}
+
+// boolInt converts a flag to the 0/1 form used by the text format
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// checkBMFontString rejects a face or file name the text format cannot hold.
+// Quoted values end at the next quote and readers know no escapes.
+func checkBMFontString(s string) error {
+	if strings.ContainsAny(s, "\"\\\n") {
+		return fmt.Errorf("%q: quotes, backslashes and line breaks are not allowed in BMFont names", s)
+	}
+	return nil
+}
+
+// WriteText writes the font in the BMFont text format
+func (f *BMFont) WriteText(w io.Writer) error {
+	for _, s := range append([]string{f.Info.Face}, f.Pages...) {
+		if err := checkBMFontString(s); err != nil {
+			return err
+		}
+	}
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "info face=\"%s\" size=%d bold=%d italic=%d charset=\"\" unicode=%d stretchH=%d smooth=%d aa=%d padding=%d,%d,%d,%d spacing=%d,%d outline=%d\n",
+		f.Info.Face, f.Info.Size, boolInt(f.Info.Bold), boolInt(f.Info.Italic), boolInt(f.Info.Unicode),
+		f.Info.StretchH, boolInt(f.Info.Smooth), f.Info.AA,
+		f.Info.Padding[0], f.Info.Padding[1], f.Info.Padding[2], f.Info.Padding[3],
+		f.Info.Spacing[0], f.Info.Spacing[1], f.Info.Outline)
+	fmt.Fprintf(bw, "common lineHeight=%d base=%d scaleW=%d scaleH=%d pages=%d packed=%d alphaChnl=%d redChnl=%d greenChnl=%d blueChnl=%d\n",
+		f.Common.LineHeight, f.Common.Base, f.Common.ScaleW, f.Common.ScaleH, len(f.Pages),
+		boolInt(f.Common.Packed), f.Common.AlphaChnl, f.Common.RedChnl, f.Common.GreenChnl, f.Common.BlueChnl)
+
+	for i, page := range f.Pages {
+		fmt.Fprintf(bw, "page id=%d file=\"%s\"\n", i, page)
+	}
+
+	fmt.Fprintf(bw, "chars count=%d\n", len(f.Chars))
+	for _, c := range f.Chars {
+		fmt.Fprintf(bw, "char id=%d x=%d y=%d width=%d height=%d xoffset=%d yoffset=%d xadvance=%d page=%d chnl=%d\n",
+			c.ID, c.X, c.Y, c.Width, c.Height, c.XOffset, c.YOffset, c.XAdvance, c.Page, c.Chnl)
+	}
+
+	if len(f.Kernings) > 0 {
+		fmt.Fprintf(bw, "kernings count=%d\n", len(f.Kernings))
+		for _, k := range f.Kernings {
+			fmt.Fprintf(bw, "kerning first=%d second=%d amount=%d\n", k.First, k.Second, k.Amount)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteBinary writes the font in the BMFont binary format (version 3)
+func (f *BMFont) WriteBinary(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("BMF")
+	buf.WriteByte(3)
+
+	writeBlock := func(blockType byte, data []byte) {
+		buf.WriteByte(blockType)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	// Block 1: info
+	var info bytes.Buffer
+	binary.Write(&info, binary.LittleEndian, int16(f.Info.Size))
+	var infoBits byte
+	if f.Info.Smooth {
+		infoBits |= 1 << 0
+	}
+	if f.Info.Unicode {
+		infoBits |= 1 << 1
+	}
+	if f.Info.Italic {
+		infoBits |= 1 << 2
+	}
+	if f.Info.Bold {
+		infoBits |= 1 << 3
+	}
+	info.WriteByte(infoBits)
+	info.WriteByte(0) // charSet, unused with unicode
+	binary.Write(&info, binary.LittleEndian, uint16(f.Info.StretchH))
+	info.WriteByte(byte(f.Info.AA))
+	for _, p := range f.Info.Padding {
+		info.WriteByte(byte(p))
+	}
+	info.WriteByte(byte(f.Info.Spacing[0]))
+	info.WriteByte(byte(f.Info.Spacing[1]))
+	info.WriteByte(byte(f.Info.Outline))
+	info.WriteString(f.Info.Face)
+	info.WriteByte(0)
+	writeBlock(1, info.Bytes())
+
+	// Block 2: common
+	var common bytes.Buffer
+	binary.Write(&common, binary.LittleEndian, []uint16{
+		uint16(f.Common.LineHeight), uint16(f.Common.Base),
+		uint16(f.Common.ScaleW), uint16(f.Common.ScaleH), uint16(len(f.Pages)),
+	})
+	var commonBits byte
+	if f.Common.Packed {
+		commonBits |= 1 << 7
+	}
+	common.WriteByte(commonBits)
+	common.Write([]byte{
+		byte(f.Common.AlphaChnl), byte(f.Common.RedChnl),
+		byte(f.Common.GreenChnl), byte(f.Common.BlueChnl),
+	})
+	writeBlock(2, common.Bytes())
+
+	// Block 3: page names, each null terminated
+	var pages bytes.Buffer
+	for _, page := range f.Pages {
+		pages.WriteString(page)
+		pages.WriteByte(0)
+	}
+	writeBlock(3, pages.Bytes())
+
+	// Block 4: chars, 20 bytes each
+	var chars bytes.Buffer
+	for _, c := range f.Chars {
+		binary.Write(&chars, binary.LittleEndian, uint32(c.ID))
+		binary.Write(&chars, binary.LittleEndian, []uint16{
+			uint16(c.X), uint16(c.Y), uint16(c.Width), uint16(c.Height),
+		})
+		binary.Write(&chars, binary.LittleEndian, []int16{
+			int16(c.XOffset), int16(c.YOffset), int16(c.XAdvance),
+		})
+		chars.WriteByte(byte(c.Page))
+		chars.WriteByte(byte(c.Chnl))
+	}
+	writeBlock(4, chars.Bytes())
+
+	// Block 5: kerning pairs, 10 bytes each (omitted when empty)
+	if len(f.Kernings) > 0 {
+		var kernings bytes.Buffer
+		for _, k := range f.Kernings {
+			binary.Write(&kernings, binary.LittleEndian, []uint32{uint32(k.First), uint32(k.Second)})
+			binary.Write(&kernings, binary.LittleEndian, int16(k.Amount))
+		}
+		writeBlock(5, kernings.Bytes())
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeBMFontFile writes one format variant
+func writeBMFontFile(font *BMFont, path string, binaryFormat bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if binaryFormat {
+		err = font.WriteBinary(file)
+	} else {
+		err = font.WriteText(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// runBMFont implements the bmfont subcommand
+func runBMFont(args []string) error {
+	config := DefaultAtlasConfig()
+	var outputDir string
+	var name string
+	var face string
+	var format string
+
+	fs := flag.NewFlagSet("bmfont", flag.ExitOnError)
+	fs.StringVar(&outputDir, "output", "", "Output directory (default bmfont next to glyphs.json)")
+	fs.StringVar(&name, "name", "handwritten", "Base name for the .fnt file and atlas pages")
+	fs.StringVar(&face, "face", "Handwritten", "Font face name written into the .fnt file")
+	fs.StringVar(&format, "format", "both", "Output format: text, binary or both")
+	addAtlasFlags(fs, &config)
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor bmfont [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	writeText := format == "text" || format == "both"
+	writeBinary := format == "binary" || format == "both"
+	if !writeText && !writeBinary {
+		return fmt.Errorf("unknown format %q (want text, binary or both)", format)
+	}
+	if writeText {
+		for _, s := range []string{face, name} {
+			if err := checkBMFontString(s); err != nil {
+				return err
+			}
+		}
+	}
+
+	// BMFont stores page sizes as 16-bit values
+	if config.MaxSize > 65535 {
+		return fmt.Errorf("max size %d exceeds the BMFont limit of 65535", config.MaxSize)
+	}
+
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if outputDir == "" {
+		outputDir = filepath.Join(filepath.Dir(manifestPath), "bmfont")
+	}
+
+	set, atlas, err := loadAndPack(manifestPath, config)
+	if err != nil {
+		return err
+	}
+
+	pages, err := writeAtlasPages(atlas, outputDir, name)
+	if err != nil {
+		return err
+	}
+
+	font := NewBMFont(set, atlas, config, face, pages)
+
+	if writeText {
+		path := filepath.Join(outputDir, name+".fnt")
+		if err := writeBMFontFile(font, path, false); err != nil {
+			return err
+		}
+		fmt.Printf("Text font: %s\n", path)
+	}
+	if writeBinary {
+		path := filepath.Join(outputDir, name+"_bin.fnt")
+		if err := writeBMFontFile(font, path, true); err != nil {
+			return err
+		}
+		fmt.Printf("Binary font: %s\n", path)
+	}
+
+	fmt.Printf("Done! %d chars on %d page(s)\n", len(font.Chars), len(font.Pages))
+	return nil
+}
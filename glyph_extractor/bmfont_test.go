@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"glyph_extractor/manifest"
+)
+
+// testBMFont is a font using every feature the writers support: several
+// pages, characters beyond ASCII, negative offsets and kerning
+func testBMFont() *BMFont {
+	return &BMFont{
+		Info: BMFontInfo{
+			Face:     "My Hand 2",
+			Size:     97,
+			Unicode:  true,
+			Smooth:   true,
+			StretchH: 100,
+			AA:       1,
+			Spacing:  [2]int{2, 2},
+		},
+		Common: BMFontCommon{
+			LineHeight: 97,
+			Base:       73,
+			ScaleW:     1024,
+			ScaleH:     512,
+		},
+		Pages: []string{"handwritten_0.png", "handwritten_1.png"},
+		Chars: []BMFontChar{
+			{ID: 'A', X: 2, Y: 2, Width: 40, Height: 61, XOffset: 0, YOffset: 12, XAdvance: 44, Page: 0, Chnl: bmfontAllChannels},
+			{ID: 'j', X: 44, Y: 2, Width: 20, Height: 70, XOffset: -6, YOffset: 25, XAdvance: 18, Page: 0, Chnl: bmfontAllChannels},
+			{ID: 'ř', X: 2, Y: 2, Width: 26, Height: 52, XOffset: 1, YOffset: 21, XAdvance: 30, Page: 1, Chnl: bmfontAllChannels},
+			{ID: '€', X: 30, Y: 2, Width: 38, Height: 60, XOffset: 2, YOffset: 13, XAdvance: 42, Page: 1, Chnl: bmfontAllChannels},
+		},
+		Kernings: []BMFontKerning{
+			{First: 'A', Second: 'j', Amount: -7},
+			{First: 'ř', Second: '€', Amount: 3},
+		},
+	}
+}
+
+func TestBMFontTextRoundTrip(t *testing.T) {
+	font := testBMFont()
+	var buf bytes.Buffer
+	if err := font.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseBMFontText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(font, parsed) {
+		t.Errorf("parsed font differs:\n got %+v\nwant %+v", parsed, font)
+	}
+}
+
+func TestBMFontBinaryRoundTrip(t *testing.T) {
+	font := testBMFont()
+	var buf bytes.Buffer
+	if err := font.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseBMFontBinary(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(font, parsed) {
+		t.Errorf("parsed font differs:\n got %+v\nwant %+v", parsed, font)
+	}
+}
+
+// testGlyph is a glyph image of the given size with ink only in the ink
+// rectangle, so packing trims the transparent border around it
+func testGlyph(key string, width, height int, ink image.Rectangle, baseline int) *manifest.Glyph {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := ink.Min.Y; y < ink.Max.Y; y++ {
+		for x := ink.Min.X; x < ink.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+	return &manifest.Glyph{Key: key, File: key + ".png", Image: img, Metrics: manifest.GlyphMetrics{
+		Width: width, Height: height, Baseline: baseline,
+	}}
+}
+
+func TestBMFontExport(t *testing.T) {
+	// At 50 DPI the cell is 51px high with the baseline at 38px
+	ink := map[string]image.Rectangle{
+		"A":  image.Rect(2, 3, 28, 33),
+		"g":  image.Rect(1, 2, 19, 30), // Descender below the baseline
+		"Ť":  image.Rect(0, 0, 24, 44), // Rises above the cell: negative yoffset
+		"ž":  image.Rect(3, 1, 20, 26),
+		"ch": image.Rect(2, 2, 36, 34), // Ligature, not a single code point
+	}
+	set := &manifest.GlyphSet{
+		Manifest: manifest.GlyphsJSON{
+			Version:  1,
+			CellSize: manifest.CellSize{Width: 22.5, Height: 26.2},
+			DPI:      50,
+			Kerning: []manifest.KerningPair{
+				{Left: "A", Right: "ž", Amount: -5},
+				{Left: "ch", Right: "A", Amount: 3},
+			},
+		},
+		Glyphs: map[string]*manifest.Glyph{
+			"A":  testGlyph("A", 32, 36, ink["A"], 33),
+			"g":  testGlyph("g", 22, 32, ink["g"], 20),
+			"Ť":  testGlyph("Ť", 26, 46, ink["Ť"], 44),
+			"ž":  testGlyph("ž", 24, 28, ink["ž"], 26),
+			"ch": testGlyph("ch", 40, 36, ink["ch"], 34),
+		},
+	}
+	_, base := set.LineBox()
+	if base-set.Glyphs["Ť"].Metrics.Baseline >= 0 {
+		t.Fatalf("test set needs a glyph above the line box, base is %d", base)
+	}
+
+	// Small pages so the glyphs spread over several
+	config := DefaultAtlasConfig()
+	config.MaxSize = 64
+	atlas, err := PackAtlas(set, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atlas.Pages) < 2 {
+		t.Fatalf("want several atlas pages, got %d", len(atlas.Pages))
+	}
+	pages, err := writeAtlasPages(atlas, t.TempDir(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font := NewBMFont(set, atlas, config, "Test Hand", pages)
+
+	var text, bin bytes.Buffer
+	if err := font.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if err := font.WriteBinary(&bin); err != nil {
+		t.Fatal(err)
+	}
+	fromText, err := parseBMFontText(&text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary, err := parseBMFontBinary(bin.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, parsed := range map[string]*BMFont{"text": fromText, "binary": fromBinary} {
+		if parsed.Common.Base != base {
+			t.Errorf("%s: base %d, want %d", name, parsed.Common.Base, base)
+		}
+		if len(parsed.Pages) != len(pages) {
+			t.Errorf("%s: %d pages, want %d", name, len(parsed.Pages), len(pages))
+		}
+
+		chars := make(map[rune]BMFontChar)
+		for _, c := range parsed.Chars {
+			chars[c.ID] = c
+		}
+		if len(chars) != 4 {
+			t.Errorf("%s: %d chars, want 4 without the ligature", name, len(chars))
+		}
+		for _, key := range []string{"A", "g", "Ť", "ž"} {
+			r := []rune(key)[0]
+			c, ok := chars[r]
+			if !ok {
+				t.Errorf("%s: '%s' missing", name, key)
+				continue
+			}
+			glyph, frame := set.Glyphs[key], atlas.Frames[key]
+			want := BMFontChar{
+				ID:       r,
+				X:        frame.Frame.X,
+				Y:        frame.Frame.Y,
+				Width:    ink[key].Dx(),
+				Height:   ink[key].Dy(),
+				XOffset:  ink[key].Min.X,
+				YOffset:  base - glyph.Metrics.Baseline + ink[key].Min.Y,
+				XAdvance: glyph.Image.Bounds().Dx(),
+				Page:     frame.Page,
+				Chnl:     bmfontAllChannels,
+			}
+			if c != want {
+				t.Errorf("%s: '%s' is %+v, want %+v", name, key, c, want)
+			}
+			if frame.Frame.W != want.Width || frame.Frame.H != want.Height {
+				t.Errorf("%s: '%s' frame is %dx%d, want the %dx%d ink", name, key, frame.Frame.W, frame.Frame.H, want.Width, want.Height)
+			}
+		}
+
+		wantKerning := []BMFontKerning{{First: 'A', Second: 'ž', Amount: -5}}
+		if !reflect.DeepEqual(parsed.Kernings, wantKerning) {
+			t.Errorf("%s: kernings %+v, want %+v", name, parsed.Kernings, wantKerning)
+		}
+	}
+}
+
+func TestBMFontTextRejectsQuotes(t *testing.T) {
+	for _, face := range []string{`My "Hand"`, `C:\Hand`, "Two\nLines"} {
+		font := testBMFont()
+		font.Info.Face = face
+		if err := font.WriteText(io.Discard); err == nil {
+			t.Errorf("face %q was written", face)
+		}
+	}
+
+	font := testBMFont()
+	font.Pages[1] = `page"1.png`
+	if err := font.WriteText(io.Discard); err == nil {
+		t.Errorf("page %q was written", font.Pages[1])
+	}
+}
+
+// parseBMFontTags splits a text format line into its tag and key=value pairs
+func parseBMFontTags(line string) (string, map[string]string) {
+	values := make(map[string]string)
+	tag, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(after, "\"") {
+			end := strings.Index(after[1:], "\"")
+			if end < 0 {
+				end = len(after) - 1
+			}
+			values[key] = after[1 : end+1]
+			rest = after[min(end+2, len(after)):]
+			continue
+		}
+		value, next, _ := strings.Cut(after, " ")
+		values[key] = value
+		rest = next
+	}
+	return tag, values
+}
+
+// parseBMFontText reads a font in the BMFont text format
+func parseBMFontText(r io.Reader) (*BMFont, error) {
+	font := &BMFont{}
+	var parseErr error
+
+	atoi := func(values map[string]string, key string) int {
+		n, err := strconv.Atoi(values[key])
+		if err != nil && parseErr == nil {
+			parseErr = fmt.Errorf("invalid %s=%q", key, values[key])
+		}
+		return n
+	}
+	ints := func(values map[string]string, key string, out []int) {
+		parts := strings.Split(values[key], ",")
+		if len(parts) != len(out) {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("invalid %s=%q", key, values[key])
+			}
+			return
+		}
+		for i, part := range parts {
+			out[i] = atoi(map[string]string{key: part}, key)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		tag, v := parseBMFontTags(scanner.Text())
+
+		switch tag {
+		case "info":
+			font.Info.Face = v["face"]
+			font.Info.Size = atoi(v, "size")
+			font.Info.Bold = atoi(v, "bold") != 0
+			font.Info.Italic = atoi(v, "italic") != 0
+			font.Info.Unicode = atoi(v, "unicode") != 0
+			font.Info.StretchH = atoi(v, "stretchH")
+			font.Info.Smooth = atoi(v, "smooth") != 0
+			font.Info.AA = atoi(v, "aa")
+			ints(v, "padding", font.Info.Padding[:])
+			ints(v, "spacing", font.Info.Spacing[:])
+			font.Info.Outline = atoi(v, "outline")
+		case "common":
+			font.Common = BMFontCommon{
+				LineHeight: atoi(v, "lineHeight"),
+				Base:       atoi(v, "base"),
+				ScaleW:     atoi(v, "scaleW"),
+				ScaleH:     atoi(v, "scaleH"),
+				Packed:     atoi(v, "packed") != 0,
+				AlphaChnl:  atoi(v, "alphaChnl"),
+				RedChnl:    atoi(v, "redChnl"),
+				GreenChnl:  atoi(v, "greenChnl"),
+				BlueChnl:   atoi(v, "blueChnl"),
+			}
+			font.Pages = make([]string, atoi(v, "pages"))
+		case "page":
+			id := atoi(v, "id")
+			if id < 0 || id >= len(font.Pages) {
+				return nil, fmt.Errorf("page id %d out of range", id)
+			}
+			font.Pages[id] = v["file"]
+		case "char":
+			font.Chars = append(font.Chars, BMFontChar{
+				ID:       rune(atoi(v, "id")),
+				X:        atoi(v, "x"),
+				Y:        atoi(v, "y"),
+				Width:    atoi(v, "width"),
+				Height:   atoi(v, "height"),
+				XOffset:  atoi(v, "xoffset"),
+				YOffset:  atoi(v, "yoffset"),
+				XAdvance: atoi(v, "xadvance"),
+				Page:     atoi(v, "page"),
+				Chnl:     atoi(v, "chnl"),
+			})
+		case "kerning":
+			font.Kernings = append(font.Kernings, BMFontKerning{
+				First:  rune(atoi(v, "first")),
+				Second: rune(atoi(v, "second")),
+				Amount: atoi(v, "amount"),
+			})
+		case "chars", "kernings":
+			// Counts only — the entries themselves follow
+		default:
+			return nil, fmt.Errorf("unknown tag %q", tag)
+		}
+
+		if parseErr != nil {
+			return nil, fmt.Errorf("%s line: %w", tag, parseErr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return font, nil
+}
+
+// parseBMFontBinary reads a font in the BMFont binary format (version 3)
+func parseBMFontBinary(data []byte) (*BMFont, error) {
+	if len(data) < 4 || string(data[:3]) != "BMF" {
+		return nil, fmt.Errorf("not a binary BMFont file")
+	}
+	if data[3] != 3 {
+		return nil, fmt.Errorf("unsupported BMFont version %d", data[3])
+	}
+
+	font := &BMFont{}
+	le := binary.LittleEndian
+	data = data[4:]
+
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, fmt.Errorf("truncated block header")
+		}
+		blockType := data[0]
+		size := int(le.Uint32(data[1:5]))
+		if len(data) < 5+size {
+			return nil, fmt.Errorf("truncated block %d", blockType)
+		}
+		block := data[5 : 5+size]
+		data = data[5+size:]
+
+		switch blockType {
+		case 1:
+			if size < 15 {
+				return nil, fmt.Errorf("info block too short")
+			}
+			font.Info = BMFontInfo{
+				Size:     int(int16(le.Uint16(block[0:2]))),
+				Smooth:   block[2]&(1<<0) != 0,
+				Unicode:  block[2]&(1<<1) != 0,
+				Italic:   block[2]&(1<<2) != 0,
+				Bold:     block[2]&(1<<3) != 0,
+				StretchH: int(le.Uint16(block[4:6])),
+				AA:       int(block[6]),
+				Padding:  [4]int{int(block[7]), int(block[8]), int(block[9]), int(block[10])},
+				Spacing:  [2]int{int(block[11]), int(block[12])},
+				Outline:  int(block[13]),
+				Face:     string(bytes.TrimRight(block[14:], "\x00")),
+			}
+		case 2:
+			if size < 15 {
+				return nil, fmt.Errorf("common block too short")
+			}
+			font.Common = BMFontCommon{
+				LineHeight: int(le.Uint16(block[0:2])),
+				Base:       int(le.Uint16(block[2:4])),
+				ScaleW:     int(le.Uint16(block[4:6])),
+				ScaleH:     int(le.Uint16(block[6:8])),
+				Packed:     block[10]&(1<<7) != 0,
+				AlphaChnl:  int(block[11]),
+				RedChnl:    int(block[12]),
+				GreenChnl:  int(block[13]),
+				BlueChnl:   int(block[14]),
+			}
+		case 3:
+			for _, name := range bytes.Split(bytes.TrimSuffix(block, []byte{0}), []byte{0}) {
+				font.Pages = append(font.Pages, string(name))
+			}
+		case 4:
+			if size%20 != 0 {
+				return nil, fmt.Errorf("chars block size %d is not a multiple of 20", size)
+			}
+			for i := 0; i < size; i += 20 {
+				c := block[i : i+20]
+				font.Chars = append(font.Chars, BMFontChar{
+					ID:       rune(le.Uint32(c[0:4])),
+					X:        int(le.Uint16(c[4:6])),
+					Y:        int(le.Uint16(c[6:8])),
+					Width:    int(le.Uint16(c[8:10])),
+					Height:   int(le.Uint16(c[10:12])),
+					XOffset:  int(int16(le.Uint16(c[12:14]))),
+					YOffset:  int(int16(le.Uint16(c[14:16]))),
+					XAdvance: int(int16(le.Uint16(c[16:18]))),
+					Page:     int(c[18]),
+					Chnl:     int(c[19]),
+				})
+			}
+		case 5:
+			if size%10 != 0 {
+				return nil, fmt.Errorf("kerning block size %d is not a multiple of 10", size)
+			}
+			for i := 0; i < size; i += 10 {
+				k := block[i : i+10]
+				font.Kernings = append(font.Kernings, BMFontKerning{
+					First:  rune(le.Uint32(k[0:4])),
+					Second: rune(le.Uint32(k[4:8])),
+					Amount: int(int16(le.Uint16(k[8:10]))),
+				})
+			}
+		default:
+			return nil, fmt.Errorf("unknown block type %d", blockType)
+		}
+	}
+	return font, nil
+}
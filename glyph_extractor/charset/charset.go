@@ -0,0 +1,461 @@
+// Package charset defines the characters of the handwriting template, their
+// order on the pages and the glyph file names derived from them.
+package charset
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset defines the fixed order of characters in the grid.
+// An entry of several runes is a ligature written as one connected glyph; an
+// entry with a position suffix ("a.fina") is a positional form of a letter.
+// Page 1: 80 characters (uppercase + numbers + punctuation)
+// Page 2: 80 characters (lowercase + extra symbols)
+// Page 3: ligatures + positional forms
+var Charset = []string{
+	// Page 1 (80 characters)
+	// Row 1: Uppercase A-E with diacritics
+	"A", "Á", "B", "C", "Č", "D", "Ď", "E",
+	// Row 2: Uppercase E-J with diacritics
+	"É", "Ě", "F", "G", "H", "I", "Í", "J",
+	// Row 3: Uppercase K-P with diacritics
+	"K", "L", "M", "N", "Ň", "O", "Ó", "P",
+	// Row 4: Uppercase Q-U with diacritics
+	"Q", "R", "Ř", "S", "Š", "T", "Ť", "U",
+	// Row 5: Uppercase U-Z with diacritics
+	"Ú", "Ů", "V", "W", "X", "Y", "Ý", "Z",
+	// Row 6: Ž + digits 0-6
+	"Ž", "0", "1", "2", "3", "4", "5", "6",
+	// Row 7: digits 7-9 + basic punctuation
+	"7", "8", "9", ".", ",", "!", "?", ":",
+	// Row 8: more punctuation
+	";", "-", "(", ")", "\"", "'", "/", "@",
+	// Row 9: symbols
+	"#", "&", "+", "=", "%", "*", "€", "$",
+	// Row 10: brackets and special
+	"[", "]", "{", "}", "<", ">", "\\", "_",
+
+	// Page 2 (80 characters)
+	// Row 1: Lowercase a-e with diacritics
+	"a", "á", "b", "c", "č", "d", "ď", "e",
+	// Row 2: Lowercase e-j with diacritics
+	"é", "ě", "f", "g", "h", "i", "í", "j",
+	// Row 3: Lowercase k-p with diacritics
+	"k", "l", "m", "n", "ň", "o", "ó", "p",
+	// Row 4: Lowercase q-u with diacritics
+	"q", "r", "ř", "s", "š", "t", "ť", "u",
+	// Row 5: Lowercase u-z with diacritics
+	"ú", "ů", "v", "w", "x", "y", "ý", "z",
+	// Row 6: ž + misc symbols
+	"ž", "~", "`", "^", "|", "©", "®", "™",
+	// Row 7: typographic symbols
+	"°", "§", "¶", "•", "…", "–", "—", "„",
+	// Row 8: quotes and math
+	"\u201D", "‚", "\u2019", "«", "»", "×", "÷", "±", // " ‚ ' « » × ÷ ±
+	// Row 9: fractions and superscripts
+	"¼", "½", "¾", "¹", "²", "³", "µ", "¿",
+	// Row 10: inverted and foreign
+	"¡", "ñ", "Ñ", "ß", "æ", "Æ", "ø", "Ø",
+
+	// Page 3 (16 ligatures)
+	// Row 1: ch is a letter of its own in Czech
+	"ch", "Ch", "CH", "tt", "ll", "ou", "oo", "ov",
+	// Row 2: other common connected pairs
+	"st", "ck", "ff", "nn", "mm", "ss", "př", "ně",
+
+	// Page 3 (32 positional forms)
+	// Rows 3-4: word-final letters
+	"a.fina", "á.fina", "e.fina", "é.fina", "ě.fina", "i.fina", "í.fina", "o.fina",
+	"u.fina", "y.fina", "k.fina", "l.fina", "m.fina", "n.fina", "s.fina", "t.fina",
+	// Rows 5-6: word-initial letters
+	"a.init", "b.init", "c.init", "d.init", "h.init", "j.init", "k.init", "l.init",
+	"m.init", "n.init", "o.init", "p.init", "s.init", "t.init", "v.init", "z.init",
+}
+
+// Positions of a letter within a word, named after the OpenType features
+const (
+	PositionInitial  = "init" // First letter of a word
+	PositionMedial   = "medi" // Letter inside a word
+	PositionFinal    = "fina" // Last letter of a word
+	PositionIsolated = "isol" // Single-letter word
+)
+
+// SplitPosition splits a positional form such as "a.fina" into its base
+// character and position. Entries without a position suffix are returned
+// unchanged with an empty position.
+func SplitPosition(char string) (base, position string) {
+	i := strings.LastIndex(char, ".")
+	if i <= 0 {
+		return char, ""
+	}
+	switch suffix := char[i+1:]; suffix {
+	case PositionInitial, PositionMedial, PositionFinal, PositionIsolated:
+		return char[:i], suffix
+	}
+	return char, ""
+}
+
+// CharToFilename converts a charset entry to a safe ASCII filename.
+// Ligatures join the names of their characters with "-", e.g. "c-h", and
+// positional forms keep their suffix, e.g. "a.fina".
+func CharToFilename(char string) string {
+	if base, position := SplitPosition(char); position != "" {
+		return CharToFilename(base) + "." + position
+	}
+
+	runes := []rune(char)
+	if len(runes) == 1 {
+		return runeToFilename(runes[0])
+	}
+
+	names := make([]string, len(runes))
+	for i, r := range runes {
+		names[i] = runeToFilename(r)
+	}
+	return strings.Join(names, "-")
+}
+
+// IsLigature reports whether a charset entry spans several characters
+func IsLigature(char string) bool {
+	base, _ := SplitPosition(char)
+	return utf8.RuneCountInString(base) > 1
+}
+
+// runeToFilename converts a single character to a safe ASCII filename
+// All non-alphanumeric characters are mapped to descriptive names
+func runeToFilename(r rune) string {
+	switch r {
+	// Basic punctuation
+	case '.':
+		return "dot"
+	case ',':
+		return "comma"
+	case '!':
+		return "exclaim"
+	case '?':
+		return "question"
+	case ':':
+		return "colon"
+	case ';':
+		return "semicolon"
+	case '-':
+		return "hyphen"
+	case '_':
+		return "underscore"
+	case '\'':
+		return "apostrophe"
+	case '"':
+		return "doublequote"
+	case '/':
+		return "slash"
+	case '\\':
+		return "backslash"
+	case '@':
+		return "at"
+	case '#':
+		return "hash"
+	case '&':
+		return "ampersand"
+	case '+':
+		return "plus"
+	case '=':
+		return "equals"
+	case '%':
+		return "percent"
+	case '*':
+		return "asterisk"
+	case '$':
+		return "dollar"
+	case ' ':
+		return "space"
+
+	// Brackets
+	case '(':
+		return "lparen"
+	case ')':
+		return "rparen"
+	case '[':
+		return "lbracket"
+	case ']':
+		return "rbracket"
+	case '{':
+		return "lbrace"
+	case '}':
+		return "rbrace"
+	case '<':
+		return "less"
+	case '>':
+		return "greater"
+
+	// Special ASCII
+	case '~':
+		return "tilde"
+	case '`':
+		return "backtick"
+	case '^':
+		return "caret"
+	case '|':
+		return "pipe"
+
+	// Czech uppercase with diacritics
+	case 'Á':
+		return "A_acute"
+	case 'Č':
+		return "C_caron"
+	case 'Ď':
+		return "D_caron"
+	case 'É':
+		return "E_acute"
+	case 'Ě':
+		return "E_caron"
+	case 'Í':
+		return "I_acute"
+	case 'Ň':
+		return "N_caron"
+	case 'Ó':
+		return "O_acute"
+	case 'Ř':
+		return "R_caron"
+	case 'Š':
+		return "S_caron"
+	case 'Ť':
+		return "T_caron"
+	case 'Ú':
+		return "U_acute"
+	case 'Ů':
+		return "U_ring"
+	case 'Ý':
+		return "Y_acute"
+	case 'Ž':
+		return "Z_caron"
+
+	// Czech lowercase with diacritics
+	case 'á':
+		return "a_acute"
+	case 'č':
+		return "c_caron"
+	case 'ď':
+		return "d_caron"
+	case 'é':
+		return "e_acute"
+	case 'ě':
+		return "e_caron"
+	case 'í':
+		return "i_acute"
+	case 'ň':
+		return "n_caron"
+	case 'ó':
+		return "o_acute"
+	case 'ř':
+		return "r_caron"
+	case 'š':
+		return "s_caron"
+	case 'ť':
+		return "t_caron"
+	case 'ú':
+		return "u_acute"
+	case 'ů':
+		return "u_ring"
+	case 'ý':
+		return "y_acute"
+	case 'ž':
+		return "z_caron"
+
+	// Currency and symbols
+	case '€':
+		return "euro"
+	case '©':
+		return "copyright"
+	case '®':
+		return "registered"
+	case '™':
+		return "trademark"
+	case '°':
+		return "degree"
+	case '§':
+		return "section"
+	case '¶':
+		return "pilcrow"
+	case '•':
+		return "bullet"
+	case '…':
+		return "ellipsis"
+
+	// Dashes and quotes
+	case '–':
+		return "endash"
+	case '—':
+		return "emdash"
+	case '„':
+		return "quotelowdbl"
+	case '\u201D': // "
+		return "quoterightdbl"
+	case '‚':
+		return "quotelowsgl"
+	case '\u2019': // '
+		return "quoteright"
+	case '«':
+		return "guillemotleft"
+	case '»':
+		return "guillemotright"
+
+	// Math symbols
+	case '×':
+		return "multiply"
+	case '÷':
+		return "divide"
+	case '±':
+		return "plusminus"
+
+	// Fractions and superscripts
+	case '¼':
+		return "onequarter"
+	case '½':
+		return "onehalf"
+	case '¾':
+		return "threequarters"
+	case '¹':
+		return "onesuperior"
+	case '²':
+		return "twosuperior"
+	case '³':
+		return "threesuperior"
+	case 'µ':
+		return "micro"
+	case '¿':
+		return "questiondown"
+	case '¡':
+		return "exclamdown"
+
+	// Foreign characters
+	case 'ñ':
+		return "n_tilde"
+	case 'Ñ':
+		return "N_tilde"
+	case 'ß':
+		return "eszett"
+	case 'æ':
+		return "ae"
+	case 'Æ':
+		return "AE"
+	case 'ø':
+		return "o_stroke"
+	case 'Ø':
+		return "O_stroke"
+
+	// Default: return as-is (for A-Z, a-z, 0-9)
+	default:
+		return string(r)
+	}
+}
+
+// KerningPairs lists the letter pairs printed on the pairs practice sheet.
+// They cover the classic problem pairs plus the most frequent Czech bigrams;
+// each is written once in a wide cell so the real spacing can be measured.
+var KerningPairs = []string{
+	// Page 1 (40 pairs)
+	// Rows 1-3: uppercase overhangs
+	"To", "Ta", "Te", "Tr",
+	"Ty", "Tě", "AV", "AT",
+	"AY", "Av", "LT", "LV",
+	// Rows 4-5: more uppercase pairs
+	"LY", "PA", "VA", "Va",
+	"Vo", "WA", "Wa", "Yo",
+	// Rows 6-7: lowercase overhangs and punctuation
+	"ř,", "r,", "r.", "y,",
+	"y.", "f.", "ff", "fo",
+	// Rows 8-10: frequent Czech bigrams
+	"ch", "st", "ne", "ov",
+	"po", "na", "ře", "je",
+	"to", "ko", "ní", "př",
+}
+
+// FilenameToChar converts a glyph filename (without extension) back to its character
+func FilenameToChar(name string) string {
+	if base, position := SplitPosition(name); position != "" {
+		return FilenameToChar(base) + "." + position
+	}
+
+	// Ligature names join the names of their characters with "-"; a name with an
+	// empty part is no ligature, so a raw "-.png" stays the hyphen
+	if parts := strings.Split(name, "-"); len(parts) > 1 && !slices.Contains(parts, "") {
+		var sb strings.Builder
+		for _, part := range parts {
+			sb.WriteString(FilenameToChar(part))
+		}
+		return sb.String()
+	}
+
+	switch name {
+	case "dot":
+		return "."
+	case "comma":
+		return ","
+	case "exclaim":
+		return "!"
+	case "question":
+		return "?"
+	case "colon":
+		return ":"
+	case "semicolon":
+		return ";"
+	case "hyphen":
+		return "-"
+	case "underscore":
+		return "_"
+	case "apostrophe":
+		return "'"
+	case "doublequote":
+		return "\""
+	case "slash":
+		return "/"
+	case "backslash":
+		return "\\"
+	case "at":
+		return "@"
+	case "hash":
+		return "#"
+	case "ampersand":
+		return "&"
+	case "plus":
+		return "+"
+	case "equals":
+		return "="
+	case "percent":
+		return "%"
+	case "asterisk":
+		return "*"
+	case "dollar":
+		return "$"
+	case "space":
+		return " "
+	case "lparen":
+		return "("
+	case "rparen":
+		return ")"
+	case "lbracket":
+		return "["
+	case "rbracket":
+		return "]"
+	case "lbrace":
+		return "{"
+	case "rbrace":
+		return "}"
+	case "less":
+		return "<"
+	case "greater":
+		return ">"
+	case "tilde":
+		return "~"
+	case "backtick":
+		return "`"
+	case "caret":
+		return "^"
+	case "pipe":
+		return "|"
+	default:
+		return name
+	}
+}
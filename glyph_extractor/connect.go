@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+
+	"glyph_extractor/manifest"
+)
+
+// connectorPoint maps a connector from glyph image pixels onto the canvas
+func connectorPoint(m f64.Aff3, glyph *manifest.Glyph, p *manifest.ConnectPoint) (float64, float64) {
+	b := glyph.Image.Bounds()
+	x := float64(b.Min.X+p.X) + 0.5
+	y := float64(b.Min.Y+p.Y) + 0.5
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// drawConnection draws a smooth stroke from the exit of one glyph to the
+// entry of the next. The curve leaves and arrives horizontally, like a pen
+// running along the line.
+func drawConnection(canvas *image.RGBA, x0, y0, x1, y1, width float64, ink color.NRGBA, opacity float64) {
+	if x1 <= x0 || width <= 0 {
+		return
+	}
+
+	// Cubic Bézier with horizontal tangents at both ends
+	pull := (x1 - x0) / 2
+	cx0, cy0 := x0+pull, y0
+	cx1, cy1 := x1-pull, y1
+	at := func(t float64) (float64, float64) {
+		u := 1 - t
+		x := u*u*u*x0 + 3*u*u*t*cx0 + 3*u*t*t*cx1 + t*t*t*x1
+		y := u*u*u*y0 + 3*u*u*t*cy0 + 3*u*t*t*cy1 + t*t*t*y1
+		return x, y
+	}
+
+	radius := width / 2
+	rect := image.Rect(
+		int(math.Floor(math.Min(x0, x1)-radius-1)), int(math.Floor(math.Min(y0, y1)-radius-1)),
+		int(math.Ceil(math.Max(x0, x1)+radius+1)), int(math.Ceil(math.Max(y0, y1)+radius+1)),
+	).Intersect(canvas.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	// Stamp anti-aliased discs along the curve into a coverage mask
+	mask := image.NewAlpha(rect)
+	steps := int(math.Ceil(math.Hypot(x1-x0, y1-y0)*2)) + 1
+	for i := 0; i <= steps; i++ {
+		px, py := at(float64(i) / float64(steps))
+		for y := int(math.Floor(py - radius - 1)); y <= int(math.Ceil(py+radius+1)); y++ {
+			for x := int(math.Floor(px - radius - 1)); x <= int(math.Ceil(px+radius+1)); x++ {
+				if !image.Pt(x, y).In(rect) {
+					continue
+				}
+				d := math.Hypot(float64(x)+0.5-px, float64(y)+0.5-py)
+				coverage := math.Max(0, math.Min(1, radius+0.5-d)) * opacity
+				a := uint8(math.Round(coverage * 255))
+				if a > mask.AlphaAt(x, y).A {
+					mask.SetAlpha(x, y, color.Alpha{A: a})
+				}
+			}
+		}
+	}
+
+	draw.DrawMask(canvas, rect, image.NewUniform(ink), image.Point{}, mask, rect.Min, draw.Over)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+
+	"glyph_extractor/imaging"
+	"glyph_extractor/layout"
+	"glyph_extractor/manifest"
+	"glyph_extractor/scan"
+)
+
+// Overlay colours
+var (
+	overlayCellColor     = color.NRGBA{0, 160, 60, 255}   // Cell rectangle
+	overlayTrimColor     = color.NRGBA{40, 110, 255, 255} // Glyph image cut from the cell
+	overlayBaselineColor = color.NRGBA{230, 0, 200, 255}
+)
+
+// overlayFace returns the label font for overlays of pages at the given
+// resolution, the built-in bitmap font if the TrueType one is missing
+func overlayFace(dpi int) font.Face {
+	face, err := loadFace(defaultFontPath, float64(dpi)/12)
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+// pageOverlay draws how a page was cut onto a copy of the scan: the cell
+// rectangles, the template baseline in every cell, the glyph images taken
+// from them and a label per cell. Cells flagged by the checks are outlined in
+// red.
+func pageOverlay(page image.Image, cells []manifest.Cell, config layout.GridConfig, face font.Face, flagged func(manifest.Cell) bool) *image.RGBA {
+	b := page.Bounds()
+	canvas := image.NewRGBA(b)
+	draw.Draw(canvas, b, page, b.Min, draw.Src)
+
+	width := max(2, config.DPI/150)
+	ascent := face.Metrics().Ascent.Ceil()
+
+	// Flagged cells last, so their neighbours don't paint over the red
+	ordered := make([]manifest.Cell, 0, len(cells))
+	for _, cell := range cells {
+		if !flagged(cell) {
+			ordered = append(ordered, cell)
+		}
+	}
+	for _, cell := range cells {
+		if flagged(cell) {
+			ordered = append(ordered, cell)
+		}
+	}
+
+	for _, cell := range ordered {
+		rect := cell.Rect.Rectangle()
+		cellColor := color.Color(overlayCellColor)
+		if flagged(cell) {
+			cellColor = previewWarningColor
+		}
+
+		y := rect.Min.Y + config.BaselinePx()
+		draw.Draw(canvas, image.Rect(rect.Min.X, y, rect.Max.X, y+width/2+1), image.NewUniform(overlayBaselineColor), image.Point{}, draw.Over)
+		if !cell.Empty {
+			strokeRect(canvas, cell.Trim.Rectangle(), width, overlayTrimColor)
+		}
+		strokeRect(canvas, rect, width, cellColor)
+
+		label := fmt.Sprintf("%d,%d %s", cell.Row, cell.Column, cell.Char)
+		drawText(canvas, face, rect.Min.X+2*width, rect.Min.Y+2*width+ascent, label, cellColor)
+	}
+	return canvas
+}
+
+// writeDebugOverlays writes the overlay of every page of an extraction into
+// dir/debug, one PNG per page
+func writeDebugOverlays(pages []scan.Page, cells *manifest.CellsJSON, config layout.GridConfig, dir string, log io.Writer) error {
+	debugDir := filepath.Join(dir, "debug")
+	if err := os.MkdirAll(debugDir, 0755); err != nil {
+		return err
+	}
+
+	byPage := make(map[int][]manifest.Cell)
+	for _, cell := range cells.Cells {
+		byPage[cell.Page] = append(byPage[cell.Page], cell)
+	}
+	flagged := func(cell manifest.Cell) bool { return cell.Empty || len(cell.Warnings) > 0 }
+	face := overlayFace(config.DPI)
+
+	for i, page := range pages {
+		path := filepath.Join(debugDir, fmt.Sprintf("page%d.png", i+1))
+		overlay := pageOverlay(page.Image, byPage[i+1], config, face, flagged)
+		if err := imaging.SavePNG(overlay, path); err != nil {
+			return fmt.Errorf("writing debug overlay: %w", err)
+		}
+		fmt.Fprintf(log, "Debug overlay of %s: %s\n", page.Source, path)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package extract
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+)
+
+const (
+	// noiseRatio is the size up to which a blob of ink is taken for dust or
+	// scanner noise, as a fraction of the cell area
+	noiseRatio = 0.0003
+
+	// largeComponentRatio is the size, relative to the largest blob, from
+	// which a blob counts as a stroke of its own rather than a dot or accent
+	largeComponentRatio = 0.35
+
+	// sizeOutlierRatio is how many times larger or smaller than the median of
+	// its class a glyph may be before it is reported
+	sizeOutlierRatio = 2.5
+
+	// minSizePeers is the number of glyphs a class needs for size comparisons
+	minSizePeers = 5
+)
+
+// inspectCell records a cell and checks the ink found in it. trimmed is the
+// cell cut down to its ink by TrimWhitespace, at trimRect within cellBounds.
+// A cell with nothing but noise in it is marked empty.
+func inspectCell(job cellJob, cellRect, pageBounds, cellBounds, trimRect image.Rectangle, trimmed image.Image, opts Options) manifest.Cell {
+	cell := manifest.Cell{
+		Page:      job.page + 1,
+		Row:       job.row,
+		Column:    job.col,
+		Char:      job.char,
+		Rect:      manifest.NewRect(cellRect),
+		Threshold: opts.Threshold,
+	}
+	if job.page < len(opts.PageNames) {
+		cell.Source = opts.PageNames[job.page]
+	}
+	if job.page < len(opts.PageFiles) && job.page < len(opts.PageNumbers) {
+		cell.Scan, cell.ScanPage = opts.PageFiles[job.page], opts.PageNumbers[job.page]
+	}
+	if !cellRect.In(pageBounds) {
+		cell.Warnings = append(cell.Warnings, "cell extends past the page edge")
+	}
+
+	// Same ink criterion as TrimWhitespace
+	minArea := max(1, int(noiseRatio*float64(cellRect.Dx()*cellRect.Dy())))
+	inkPixels := 0
+	var strokes []imaging.Component
+	for _, c := range imaging.NewMask(trimmed, uint8(int(opts.Threshold)*3/4)).Components() {
+		inkPixels += c.Area
+		cell.Components++
+		if c.Area >= minArea {
+			c.Bounds = c.Bounds.Add(trimRect.Min)
+			strokes = append(strokes, c)
+		}
+	}
+	cell.InkRatio = float64(inkPixels) / float64(cellRect.Dx()*cellRect.Dy())
+	if len(strokes) == 0 {
+		cell.Empty = true
+		cell.Warnings = append(cell.Warnings, "cell is empty")
+		return cell
+	}
+
+	// Ink running into the cell border most likely belongs to a neighbour or
+	// was cut off
+	var sides []string
+	for _, side := range []struct {
+		name    string
+		touches func(b image.Rectangle) bool
+	}{
+		{"top", func(b image.Rectangle) bool { return b.Min.Y <= cellBounds.Min.Y }},
+		{"bottom", func(b image.Rectangle) bool { return b.Max.Y >= cellBounds.Max.Y }},
+		{"left", func(b image.Rectangle) bool { return b.Min.X <= cellBounds.Min.X }},
+		{"right", func(b image.Rectangle) bool { return b.Max.X >= cellBounds.Max.X }},
+	} {
+		for _, c := range strokes {
+			if side.touches(c.Bounds) {
+				sides = append(sides, side.name)
+				break
+			}
+		}
+	}
+	if len(sides) > 0 {
+		cell.Warnings = append(cell.Warnings, fmt.Sprintf("ink touches the %s cell border", strings.Join(sides, " and ")))
+	}
+
+	// Letters and digits are one stroke plus dots and accents; another
+	// stroke of similar size is a stray mark or part of another glyph
+	if expected := expectedStrokes(job.char); expected > 0 {
+		largest := 0
+		for _, c := range strokes {
+			largest = max(largest, c.Area)
+		}
+		large := 0
+		for _, c := range strokes {
+			if float64(c.Area) >= largeComponentRatio*float64(largest) {
+				large++
+			}
+		}
+		if large > expected {
+			cell.Warnings = append(cell.Warnings, fmt.Sprintf("%d large separate blobs of ink, expected %d", large, expected))
+		}
+	}
+	return cell
+}
+
+// expectedStrokes returns how many blobs of ink a single letter or digit
+// may have that are large next to its body: the body and each accent, as a
+// caron can be as big as a small letter. Other glyphs return 0 and are not
+// checked; many writers lift the pen between the letters of a ligature.
+func expectedStrokes(char string) int {
+	base, _ := charset.SplitPosition(char)
+	r, size := utf8.DecodeRuneInString(base)
+	if size == 0 || size != len(base) || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return 0
+	}
+
+	strokes := 1
+	for _, m := range norm.NFD.String(base) {
+		switch {
+		case m == '\u0308' || m == '\u030B': // Diaeresis and double acute
+			strokes += 2
+		case unicode.Is(unicode.Mn, m):
+			strokes++
+		}
+	}
+	return strokes
+}
+
+// sizeClass groups glyphs of comparable size; glyphs without a class, such
+// as punctuation, are not compared
+func sizeClass(char string) string {
+	if charset.IsLigature(char) {
+		return "ligature"
+	}
+	base, _ := charset.SplitPosition(char)
+	r, _ := utf8.DecodeRuneInString(base)
+	switch {
+	case unicode.IsUpper(r):
+		return "capital letter"
+	case unicode.IsLower(r):
+		return "lowercase letter"
+	case unicode.IsDigit(r):
+		return "digit"
+	}
+	return ""
+}
+
+// checkSizes warns about glyphs far larger or smaller than the median of
+// their class, measured by the longer side of the glyph image
+func checkSizes(cells []manifest.Cell) {
+	size := func(c manifest.Cell) int { return max(c.Trim.Width, c.Trim.Height) }
+
+	sizes := make(map[string][]int)
+	for _, c := range cells {
+		if class := sizeClass(c.Char); class != "" && !c.Empty {
+			sizes[class] = append(sizes[class], size(c))
+		}
+	}
+	medians := make(map[string]int)
+	for class, s := range sizes {
+		if len(s) >= minSizePeers {
+			sort.Ints(s)
+			medians[class] = s[len(s)/2]
+		}
+	}
+
+	for i, c := range cells {
+		class := sizeClass(c.Char)
+		median, ok := medians[class]
+		if !ok || c.Empty {
+			continue
+		}
+		switch s := size(c); {
+		case float64(s) > sizeOutlierRatio*float64(median):
+			cells[i].Warnings = append(cells[i].Warnings, fmt.Sprintf("unusually large for a %s (%d px, median %d px)", class, s, median))
+		case float64(s)*sizeOutlierRatio < float64(median):
+			cells[i].Warnings = append(cells[i].Warnings, fmt.Sprintf("unusually small for a %s (%d px, median %d px)", class, s, median))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package extract
+
+import (
+	"image"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+)
+
+// xHeightLetters are flat-topped lowercase letters used to measure the x-height
+const xHeightLetters = "xzvwnmur"
+
+// EstimateXHeight returns the median height of the flat lowercase letters
+// above the baseline, or 0 when the set has none of them
+func EstimateXHeight(images map[string]image.Image, metrics map[string]manifest.GlyphMetrics) int {
+	var heights []int
+	for _, r := range xHeightLetters {
+		img, ok := images[string(r)]
+		if !ok {
+			continue
+		}
+		ink := imaging.NewMask(img, imaging.GlyphInkThreshold).InkBounds()
+		if ink.Empty() {
+			continue
+		}
+		heights = append(heights, metrics[string(r)].Baseline-(ink.Min.Y-img.Bounds().Min.Y))
+	}
+	if len(heights) == 0 {
+		return 0
+	}
+	sort.Ints(heights)
+	return heights[len(heights)/2]
+}
+
+// FindConnectors locates the entry and exit of a cursive letter: the leftmost
+// and rightmost ink close to the baseline or the x-height. Glyphs that aren't
+// letters, or have no ink near either line, get no connectors.
+func FindConnectors(char string, img image.Image, baseline, xHeight int) (entry, exit *manifest.ConnectPoint) {
+	base, _ := charset.SplitPosition(char)
+	if r, _ := utf8.DecodeRuneInString(base); !unicode.IsLetter(r) || xHeight <= 0 {
+		return nil, nil
+	}
+
+	mask := imaging.NewMask(img, imaging.GlyphInkThreshold)
+	b := mask.Bounds
+	band := max(2, xHeight/6)
+
+	// edge finds the outermost ink within band rows of the guide line at y =
+	// line, scanning columns from the left or right. The point sits in the
+	// middle of the ink in that column.
+	edge := func(line int, fromLeft bool) *manifest.ConnectPoint {
+		for i := 0; i < b.Dx(); i++ {
+			x := i
+			if !fromLeft {
+				x = b.Dx() - 1 - i
+			}
+			sum, count := 0, 0
+			for y := max(0, line-band); y <= min(b.Dy()-1, line+band); y++ {
+				if mask.At(b.Min.X+x, b.Min.Y+y) {
+					sum += y
+					count++
+				}
+			}
+			if count > 0 {
+				return &manifest.ConnectPoint{X: x, Y: sum / count}
+			}
+		}
+		return nil
+	}
+
+	// Prefer the baseline unless the x-height stroke reaches further out
+	entry = edge(baseline, true)
+	if top := edge(baseline-xHeight, true); top != nil && (entry == nil || top.X < entry.X) {
+		entry = top
+	}
+	exit = edge(baseline, false)
+	if top := edge(baseline-xHeight, false); top != nil && (exit == nil || top.X > exit.X) {
+		exit = top
+	}
+	return entry, exit
+}
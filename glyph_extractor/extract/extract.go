@@ -0,0 +1,412 @@
+// Package extract cuts the handwritten glyphs out of scanned template pages
+// and measures them.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/layout"
+	"glyph_extractor/manifest"
+	"glyph_extractor/reference"
+)
+
+// Options controls how glyphs are cut out of the template pages
+type Options struct {
+	OutputDir        string          // Glyph PNGs are written to OutputDir/glyphs; empty writes nothing
+	PageNames        []string        // Page names for the log, e.g. the scan files
+	PageFiles        []string        // Scan file of every page, recorded in the cell report
+	PageNumbers      []int           // Page of every page within its scan file, from 1
+	Threshold        uint8           // White threshold
+	Transparent      bool            // Make the background transparent
+	StrokeWidth      float64         // Normalize the pen width to this many pixels (0 = keep)
+	Ink              imaging.InkMode // Ink colour of the saved glyphs
+	InkColor         *color.NRGBA    // Colour for InkRecolor; nil uses each page's ink
+	Dropout          *color.NRGBA    // Grid colour to remove before thresholding, or nil
+	DropoutTolerance float64         // Hue distance in degrees still removed as dropout
+	Jobs             int             // Cells processed at once; 0 uses every CPU
+	Reference        *reference.Font // Font the glyphs are compared with to find misplaced characters, or nil
+	Log              io.Writer       // Progress output, in page and cell order
+}
+
+// cellJob is one template cell and the character written in it. Cells past
+// the end of the charset have no character.
+type cellJob struct {
+	page, row, col int
+	char           string
+}
+
+// cellResult is an extracted glyph
+type cellResult struct {
+	filename string
+	img      image.Image // Scanned ink, before ApplyInk
+	glyph    image.Image // In the requested ink, as saved
+	metrics  manifest.GlyphMetrics
+	cell     manifest.Cell
+}
+
+// preparedPage is a page after dropout removal, computed once by whichever
+// worker reaches one of its cells first
+type preparedPage struct {
+	once   sync.Once
+	img    image.Image
+	ink    color.NRGBA // Detected pen colour
+	header string      // Log lines printed before the page's first cell
+}
+
+// Extract cuts every cell of the pages into a glyph and returns the glyph set
+// with its manifest. The glyph PNGs are saved if opts.OutputDir is set; the
+// manifest itself is left to the caller. Cells are processed concurrently;
+// the log, the glyph files and the manifest are the same as for a sequential
+// run. The first error cancels the remaining work.
+func Extract(ctx context.Context, pages []image.Image, config layout.GridConfig, opts Options) (*manifest.GlyphSet, error) {
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	glyphsDir := ""
+	if opts.OutputDir != "" {
+		glyphsDir = filepath.Join(opts.OutputDir, "glyphs")
+		if err := os.MkdirAll(glyphsDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	// Assign characters to cells in template order
+	var jobs []cellJob
+	charIndex := 0
+	for pageIndex := range pages {
+		for row := 0; row < config.Rows; row++ {
+			for col := 0; col < config.Columns; col++ {
+				job := cellJob{page: pageIndex, row: row, col: col}
+				if charIndex < len(charset.Charset) {
+					job.char = charset.Charset[charIndex]
+					charIndex++
+				}
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	prepared := make([]preparedPage, len(pages))
+	prepare := func(i int) *preparedPage {
+		p := &prepared[i]
+		p.once.Do(func() {
+			var header strings.Builder
+			if i < len(opts.PageNames) {
+				fmt.Fprintf(&header, "Processing page %d: %s\n", i+1, opts.PageNames[i])
+			} else {
+				fmt.Fprintf(&header, "Processing page %d\n", i+1)
+			}
+			p.img = pages[i]
+			fmt.Fprintf(&header, "  Image size: %dx%d pixels\n", p.img.Bounds().Dx(), p.img.Bounds().Dy())
+
+			// Drop the printed grid before anything is thresholded
+			if opts.Dropout != nil {
+				p.img = imaging.RemoveDropout(p.img, *opts.Dropout, opts.DropoutTolerance)
+				fmt.Fprintf(&header, "  Removed dropout colour %s\n", imaging.ColorHex(*opts.Dropout))
+			}
+
+			// The pen colour of the page, used for recolouring unless one is given
+			p.ink = imaging.DominantInkColor(p.img, opts.Threshold)
+			fmt.Fprintf(&header, "  Ink colour: %s\n", imaging.ColorHex(p.ink))
+			fmt.Fprintf(&header, "  Cell size: %dx%d pixels\n", config.CellWidthPx(), config.CellHeightPx())
+			p.header = header.String()
+		})
+		return p
+	}
+
+	results := make([]*cellResult, len(jobs))
+	cells := make([]manifest.Cell, len(jobs))
+	log := newOrderedLog(opts.Log)
+	err := parallelFor(ctx, len(jobs), opts.Jobs, func(i int) error {
+		job := jobs[i]
+		page := prepare(job.page)
+
+		var out strings.Builder
+		if job.row == 0 && job.col == 0 {
+			out.WriteString(page.header)
+		}
+		if job.char == "" {
+			// Once for each template row that runs past the charset
+			if job.col == 0 || jobs[i-1].char != "" {
+				out.WriteString("  Warning: More cells than characters in charset\n")
+			}
+			log.done(i, out.String())
+			return nil
+		}
+
+		result, err := extractCell(page, job, config, opts, glyphsDir)
+		if err != nil {
+			return err
+		}
+		cells[i] = result.cell
+		if result.cell.Empty {
+			fmt.Fprintf(&out, "  [%d,%d] '%s' empty, skipped\n", job.row, job.col, job.char)
+			log.done(i, out.String())
+			return nil
+		}
+		results[i] = result
+		fmt.Fprintf(&out, "  [%d,%d] '%s' -> %s\n", job.row, job.col, job.char, result.filename)
+		log.done(i, out.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Connection points are placed relative to the x-height of the whole set
+	images := make(map[string]image.Image)
+	metricsMap := make(map[string]manifest.GlyphMetrics)
+	for i, r := range results {
+		if r != nil {
+			images[jobs[i].char] = r.img
+			metricsMap[jobs[i].char] = r.metrics
+		}
+	}
+	xHeight := EstimateXHeight(images, metricsMap)
+	shapes := make([]*reference.Shape, len(results))
+	err = parallelFor(ctx, len(results), opts.Jobs, func(i int) error {
+		r := results[i]
+		if r == nil {
+			return nil
+		}
+		r.metrics.StrokeWidth = imaging.StrokeWidth(imaging.NewMask(r.img, imaging.GlyphInkThreshold))
+		r.metrics.Entry, r.metrics.Exit = FindConnectors(jobs[i].char, r.img, r.metrics.Baseline, xHeight)
+		if opts.Reference != nil {
+			shapes[i] = reference.NewShape(r.img)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cells past the charset have no record
+	record := &manifest.CellsJSON{Version: 1}
+	var cellShapes []*reference.Shape
+	for i, job := range jobs {
+		if job.char != "" {
+			record.Cells = append(record.Cells, cells[i])
+			cellShapes = append(cellShapes, shapes[i])
+		}
+	}
+	checkSizes(record.Cells)
+	var shifts []manifest.Shift
+	var lowConfidence []string
+	if opts.Reference != nil {
+		shifts, lowConfidence = checkReference(record.Cells, cellShapes, referenceShapes(opts.Reference, record.Cells))
+	}
+
+	var empty []string
+	warnings := make(map[string][]string)
+	for _, c := range record.Cells {
+		if c.Empty {
+			empty = append(empty, c.Char)
+		} else if len(c.Warnings) > 0 {
+			warnings[c.Char] = c.Warnings
+		}
+	}
+
+	glyphs := make(map[string]*manifest.Glyph)
+	glyphsMap := make(map[string]string)
+	var ligatures []string
+	var forms []manifest.GlyphForm
+	for i, r := range results {
+		if r == nil {
+			continue
+		}
+		char := jobs[i].char
+		glyphs[char] = &manifest.Glyph{Key: char, File: r.filename, Image: r.glyph, Metrics: r.metrics}
+		glyphsMap[char] = r.filename
+		metricsMap[char] = r.metrics
+		if charset.IsLigature(char) {
+			ligatures = append(ligatures, char)
+		}
+		if base, position := charset.SplitPosition(char); position != "" {
+			forms = append(forms, manifest.GlyphForm{Glyph: char, Base: base, Position: position})
+		}
+	}
+
+	var inkColors []string
+	for i := range prepared {
+		inkColors = append(inkColors, imaging.ColorHex(prepared[i].ink))
+	}
+
+	m := manifest.GlyphsJSON{
+		Version: 1,
+		CellSize: manifest.CellSize{
+			Width:  config.CellWidthMM,
+			Height: config.CellHeightMM,
+		},
+		DPI:       config.DPI,
+		XHeight:   xHeight,
+		Glyphs:    glyphsMap,
+		Metrics:   metricsMap,
+		Stroke:    manifest.NewStrokeStats(metricsMap),
+		Ink:       opts.Ink,
+		InkColors: inkColors,
+		Ligatures: ligatures,
+		Forms:     forms,
+		Empty:     empty,
+		Warnings:  warnings,
+
+		LowConfidence: lowConfidence,
+		Shifts:        shifts,
+	}
+	return &manifest.GlyphSet{Dir: glyphsDir, Manifest: m, Glyphs: glyphs, Cells: record}, nil
+}
+
+// extractCell trims one cell, saves it as a glyph PNG into glyphsDir unless
+// that is empty, and measures the glyph and where it sat in its cell. Blank
+// cells are only recorded.
+func extractCell(page *preparedPage, job cellJob, config layout.GridConfig, opts Options, glyphsDir string) (*cellResult, error) {
+	cellRect := config.CellRect(job.row, job.col)
+	cell := config.ExtractCell(page.img, job.row, job.col)
+
+	// Trim whitespace
+	trimmed, trimRect := imaging.TrimWhitespace(cell, opts.Threshold)
+	record := inspectCell(job, cellRect, page.img.Bounds(), cell.Bounds(), trimRect, trimmed, opts)
+	if record.Empty {
+		// A blank cell gives no glyph
+		return &cellResult{cell: record}, nil
+	}
+
+	// Make transparent if requested
+	finalImg := trimmed
+	if opts.Transparent {
+		finalImg = imaging.MakeTransparent(trimmed, opts.Threshold)
+	}
+
+	// Bring the pen weight to the target width if requested
+	if opts.StrokeWidth > 0 {
+		var grow int
+		finalImg, grow = imaging.NormalizeStroke(finalImg, opts.StrokeWidth)
+		trimRect = trimRect.Inset(-grow)
+	}
+
+	// Save image in the requested ink colour; measurements use the scanned ink
+	ink := page.ink
+	if opts.InkColor != nil {
+		ink = *opts.InkColor
+	}
+	filename := charset.CharToFilename(job.char) + ".png"
+	glyph := imaging.ApplyInk(finalImg, opts.Ink, ink)
+	if glyphsDir != "" {
+		path := filepath.Join(glyphsDir, filename)
+		if err := imaging.SavePNG(glyph, path); err != nil {
+			return nil, fmt.Errorf("saving %s: %w", path, err)
+		}
+	}
+
+	// The cell is cut out of the page clipped to its edges
+	origin := cellRect.Intersect(page.img.Bounds()).Min
+	record.File = filename
+	record.Trim = manifest.NewRect(trimRect.Add(origin))
+
+	// Record where the glyph sat in its cell so it can be placed on the baseline later
+	cellY := trimRect.Min.Y - cell.Bounds().Min.Y
+	return &cellResult{
+		filename: filename,
+		img:      finalImg,
+		glyph:    glyph,
+		cell:     record,
+		metrics: manifest.GlyphMetrics{
+			Width:    trimRect.Dx(),
+			Height:   trimRect.Dy(),
+			CellX:    trimRect.Min.X - cell.Bounds().Min.X,
+			CellY:    cellY,
+			Baseline: config.BaselinePx() - cellY,
+		},
+	}, nil
+}
+
+// parallelFor calls fn for 0..n-1 on at most jobs goroutines (0 means one
+// per CPU). The first error cancels the calls not yet started and is
+// returned; so is the context's error if it is cancelled. A call that panics
+// fails with an error instead of taking the process down.
+func parallelFor(ctx context.Context, n, jobs int, fn func(i int) error) error {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(jobs, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := call(fn, i); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// call calls fn(i), turning a panic into an error
+func call(fn func(i int) error, i int) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(i)
+}
+
+// orderedLog writes the output of concurrent jobs in job order: a job's text
+// is held back until every earlier job has finished
+type orderedLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	next    int
+	pending map[int]string
+}
+
+func newOrderedLog(w io.Writer) *orderedLog {
+	return &orderedLog{w: w, pending: make(map[int]string)}
+}
+
+// done records the output of job i and flushes everything now in order
+func (l *orderedLog) done(i int, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending[i] = text
+	for {
+		text, ok := l.pending[l.next]
+		if !ok {
+			return
+		}
+		io.WriteString(l.w, text)
+		delete(l.pending, l.next)
+		l.next++
+	}
+}
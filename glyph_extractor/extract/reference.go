@@ -0,0 +1,188 @@
+package extract
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"glyph_extractor/manifest"
+	"glyph_extractor/reference"
+)
+
+const (
+	// lowSimilarity is the similarity to its reference character below
+	// which a glyph is reported as not looking like it
+	lowSimilarity = 0.25
+
+	// shiftPenalty is the similarity an alignment gives up for every change
+	// of offset; single glyphs match a neighbouring character by chance, as
+	// accented letters follow their base
+	shiftPenalty = 0.5
+
+	// minShiftRun is the number of glyphs a shifted run needs to be reported
+	minShiftRun = 4
+
+	// maxShift is the farthest a run of cells is checked to be shifted
+	maxShift = 2
+)
+
+// referenceShapes draws every character of the cells in the reference font.
+// Characters the font lacks are nil.
+func referenceShapes(ref *reference.Font, cells []manifest.Cell) []*reference.Shape {
+	shapes := make([]*reference.Shape, len(cells))
+	for i, c := range cells {
+		shapes[i], _ = ref.Shape(c.Char)
+	}
+	return shapes
+}
+
+// checkReference compares every glyph with its character in the reference
+// font and with the characters up to maxShift cells before and after it, and
+// aligns the cells with the charset: each cell is given the offset of the
+// character it holds, trading similarity against changes of offset. Runs of
+// cells off by the same amount are returned as shifts with a suggested fix;
+// their cells and glyphs that match their character poorly draw warnings.
+// shapes are the glyphs in cell order, nil for empty cells. The characters of
+// the flagged glyphs are returned too, in cell order.
+func checkReference(cells []manifest.Cell, shapes, refs []*reference.Shape) (shifts []manifest.Shift, lowConfidence []string) {
+	n := len(cells)
+	states := 2*maxShift + 1 // Offsets -maxShift..maxShift
+	score := func(i, d int) float64 {
+		j := i + d
+		switch {
+		case j < 0 || j >= n:
+			return math.Inf(-1)
+		case shapes[i] == nil || refs[j] == nil:
+			return 0
+		}
+		return reference.Similarity(shapes[i], refs[j])
+	}
+
+	for i := range cells {
+		if shapes[i] != nil && refs[i] != nil {
+			cells[i].Similarity = score(i, 0)
+		}
+	}
+
+	// Viterbi over the offsets, starting from offset 0 before the first cell
+	total := make([]float64, states)
+	for s := range states {
+		if s != maxShift {
+			total[s] = -shiftPenalty
+		}
+	}
+	from := make([][]int, n)
+	for i := 0; i < n; i++ {
+		next := make([]float64, states)
+		from[i] = make([]int, states)
+		for s := range states {
+			prev, best := s, total[s]
+			if i == 0 {
+				prev = maxShift
+			} else {
+				for p := range states {
+					if p != s && total[p]-shiftPenalty > best {
+						prev, best = p, total[p]-shiftPenalty
+					}
+				}
+			}
+			from[i][s] = prev
+			next[s] = best + score(i, s-maxShift)
+		}
+		total = next
+	}
+	offset := make([]int, n)
+	state := maxShift
+	for s := range states {
+		if total[s] > total[state] {
+			state = s
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		offset[i] = state - maxShift
+		state = from[i][state]
+	}
+
+	// Runs of glyphs with the same offset, empty cells at their ends left out
+	shifted := make([]bool, n)
+	for start := 0; start < n; {
+		d := offset[start]
+		end := start
+		for end+1 < n && offset[end+1] == d {
+			end++
+		}
+		next := end + 1
+		for start <= end && shapes[start] == nil {
+			start++
+		}
+		for end >= start && shapes[end] == nil {
+			end--
+		}
+		glyphs := 0
+		for i := start; i <= end; i++ {
+			if shapes[i] != nil {
+				glyphs++
+			}
+		}
+
+		if d != 0 && glyphs >= minShiftRun {
+			shift := manifest.Shift{
+				From:       cells[start].Char,
+				To:         cells[end].Char,
+				Offset:     d,
+				Suggestion: shiftSuggestion(cells, start, end, d),
+			}
+			shifts = append(shifts, shift)
+			for i := start; i <= end; i++ {
+				if shapes[i] != nil {
+					shifted[i] = true
+					cells[i].Warnings = append(cells[i].Warnings, fmt.Sprintf("probably holds '%s', the cells from '%s' to '%s' seem shifted by %+d",
+						cells[i+d].Char, shift.From, shift.To, d))
+				}
+			}
+		}
+		start = next
+	}
+
+	for i := range cells {
+		low := !shifted[i] && shapes[i] != nil && refs[i] != nil && cells[i].Similarity < lowSimilarity
+		if low {
+			cells[i].Warnings = append(cells[i].Warnings, fmt.Sprintf("does not look like '%s' of the reference font (similarity %.2f)", cells[i].Char, cells[i].Similarity))
+		}
+		if low || shifted[i] {
+			lowConfidence = append(lowConfidence, cells[i].Char)
+		}
+	}
+	return shifts, lowConfidence
+}
+
+// shiftSuggestion explains a run of cells from start to end that hold the
+// character offset places later in the charset, and how it came about
+func shiftSuggestion(cells []manifest.Cell, start, end, offset int) string {
+	which := "the next character"
+	switch {
+	case offset == -1:
+		which = "the previous character"
+	case offset > 1:
+		which = fmt.Sprintf("the character %d places later", offset)
+	case offset < -1:
+		which = fmt.Sprintf("the character %d places earlier", -offset)
+	}
+	held := fmt.Sprintf("the cells from '%s' to '%s' each hold %s", cells[start].Char, cells[end].Char, which)
+
+	var chars []string
+	if offset > 0 {
+		for _, c := range cells[start : start+offset] {
+			chars = append(chars, "'"+c.Char+"'")
+		}
+		verb := "seems"
+		if len(chars) > 1 {
+			verb = "seem"
+		}
+		return fmt.Sprintf("%s %s to be missing: %s", strings.Join(chars, ", "), verb, held)
+	}
+	for _, c := range cells[max(0, start+offset):start] {
+		chars = append(chars, "'"+c.Char+"'")
+	}
+	return fmt.Sprintf("a cell before '%s' holds an extra glyph, maybe %s written twice: %s", cells[start].Char, strings.Join(chars, ", "), held)
+}
@@ -3,6 +3,8 @@ module glyph_extractor
 go 1.24.5
 
 require (
-	github.com/jung-kurt/gofpdf v1.16.2 // indirect
-	golang.org/x/text v0.33.0 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2
+	golang.org/x/text v0.33.0
 )
+
+require golang.org/x/image v0.34.0
@@ -1,95 +1,89 @@
-package main
+package imaging
 
 import (
 	"image"
 	"image/color"
 )
 
-// GridConfig holds the configuration for the grid extraction
-type GridConfig struct {
-	CellWidthMM  float64 // Cell width in mm (22.5)
-	CellHeightMM float64 // Cell height in mm (26.2)
-	Columns      int     // Number of columns (8)
-	Rows         int     // Number of rows (10)
-	DPI          int     // Scanner DPI (default 300)
-	MarginTopMM  float64 // Top margin in mm
-	MarginLeftMM float64 // Left margin in mm
-}
-
-// DefaultConfig returns the default grid configuration
-func DefaultConfig() GridConfig {
-	return GridConfig{
-		CellWidthMM:  22.5,
-		CellHeightMM: 26.2,
-		Columns:      8,
-		Rows:         10,
-		DPI:          300,
-		MarginTopMM:  10.0,  // Default 10mm top margin
-		MarginLeftMM: 10.0,  // Default 10mm left margin
-	}
-}
-
-// mmToPixels converts millimeters to pixels based on DPI
-func (c GridConfig) mmToPixels(mm float64) int {
-	// 1 inch = 25.4 mm
-	inches := mm / 25.4
-	return int(inches * float64(c.DPI))
-}
-
-// CellWidthPx returns cell width in pixels
-func (c GridConfig) CellWidthPx() int {
-	return c.mmToPixels(c.CellWidthMM)
-}
-
-// CellHeightPx returns cell height in pixels
-func (c GridConfig) CellHeightPx() int {
-	return c.mmToPixels(c.CellHeightMM)
-}
-
-// MarginTopPx returns top margin in pixels
-func (c GridConfig) MarginTopPx() int {
-	return c.mmToPixels(c.MarginTopMM)
-}
-
-// MarginLeftPx returns left margin in pixels
-func (c GridConfig) MarginLeftPx() int {
-	return c.mmToPixels(c.MarginLeftMM)
-}
-
-// CellsPerPage returns the number of cells per page
-func (c GridConfig) CellsPerPage() int {
-	return c.Columns * c.Rows
-}
-
-// ExtractCell extracts a single cell from the image at the given row and column
-func (c GridConfig) ExtractCell(img image.Image, row, col int) image.Image {
-	cellW := c.CellWidthPx()
-	cellH := c.CellHeightPx()
-	marginTop := c.MarginTopPx()
-	marginLeft := c.MarginLeftPx()
-
-	x := marginLeft + col*cellW
-	y := marginTop + row*cellH
-
-	rect := image.Rect(x, y, x+cellW, y+cellH)
-	return cropImage(img, rect)
-}
-
-// cropImage crops the image to the given rectangle
-func cropImage(img image.Image, rect image.Rectangle) image.Image {
+// Crop crops the image to the given rectangle. The result starts at
+// (0, 0); RGBA, NRGBA and Gray images share their pixels with the source.
+func Crop(img image.Image, rect image.Rectangle) image.Image {
 	// Ensure rect is within image bounds
 	bounds := img.Bounds()
 	rect = rect.Intersect(bounds)
+	origin := image.Rect(0, 0, rect.Dx(), rect.Dy())
+
+	switch p := img.(type) {
+	case *image.RGBA:
+		sub := p.SubImage(rect).(*image.RGBA)
+		return &image.RGBA{Pix: sub.Pix, Stride: sub.Stride, Rect: origin}
+	case *image.NRGBA:
+		sub := p.SubImage(rect).(*image.NRGBA)
+		return &image.NRGBA{Pix: sub.Pix, Stride: sub.Stride, Rect: origin}
+	case *image.Gray:
+		sub := p.SubImage(rect).(*image.Gray)
+		return &image.Gray{Pix: sub.Pix, Stride: sub.Stride, Rect: origin}
+	}
 
-	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	cropped := image.NewRGBA(origin)
+	row := make([]pixel16, rect.Dx())
 	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			cropped.Set(x-rect.Min.X, y-rect.Min.Y, img.At(x, y))
+		readRow(img, rect.Min.X, y, row)
+		pix := cropped.Pix[cropped.PixOffset(0, y-rect.Min.Y):]
+		for i, c := range row {
+			pix[i*4] = uint8(c.r >> 8)
+			pix[i*4+1] = uint8(c.g >> 8)
+			pix[i*4+2] = uint8(c.b >> 8)
+			pix[i*4+3] = uint8(c.a >> 8)
 		}
 	}
 	return cropped
 }
 
+// pixel16 is a premultiplied colour as returned by color.Color.RGBA
+type pixel16 struct {
+	r, g, b, a uint32
+}
+
+// readRow fills row with the colours of the pixels starting at (x, y). The
+// common decoder output types are read straight from their pixel slices,
+// giving exactly what At(x, y).RGBA() would without an allocation per pixel.
+func readRow(img image.Image, x, y int, row []pixel16) {
+	switch p := img.(type) {
+	case *image.RGBA:
+		pix := p.Pix[p.PixOffset(x, y):]
+		for i := range row {
+			s := pix[i*4 : i*4+4 : i*4+4]
+			row[i] = pixel16{uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101}
+		}
+	case *image.NRGBA:
+		pix := p.Pix[p.PixOffset(x, y):]
+		for i := range row {
+			s := pix[i*4 : i*4+4 : i*4+4]
+			r, g, b, a := color.NRGBA{s[0], s[1], s[2], s[3]}.RGBA()
+			row[i] = pixel16{r, g, b, a}
+		}
+	case *image.Gray:
+		pix := p.Pix[p.PixOffset(x, y):]
+		for i := range row {
+			v := uint32(pix[i]) * 0x101
+			row[i] = pixel16{v, v, v, 0xFFFF}
+		}
+	case *image.YCbCr:
+		yi := p.YOffset(x, y)
+		for i := range row {
+			ci := p.COffset(x+i, y)
+			r, g, b, a := color.YCbCr{p.Y[yi+i], p.Cb[ci], p.Cr[ci]}.RGBA()
+			row[i] = pixel16{r, g, b, a}
+		}
+	default:
+		for i := range row {
+			r, g, b, a := img.At(x+i, y).RGBA()
+			row[i] = pixel16{r, g, b, a}
+		}
+	}
+}
+
 // TrimWhitespace removes whitespace around the glyph and returns the trimmed image
 // along with the bounding box information.
 // Uses a stricter ink detection threshold (half the transparency threshold) to ignore
@@ -107,16 +101,18 @@ func TrimWhitespace(img image.Image, threshold uint8) (image.Image, image.Rectan
 	}
 
 	// Find bounding box of non-white pixels
+	row := make([]pixel16, bounds.Dx())
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
+		readRow(img, bounds.Min.X, y, row)
+		for i, c := range row {
 			// Convert to 8-bit
-			r8 := uint8(r >> 8)
-			g8 := uint8(g >> 8)
-			b8 := uint8(b >> 8)
+			r8 := uint8(c.r >> 8)
+			g8 := uint8(c.g >> 8)
+			b8 := uint8(c.b >> 8)
 
 			// Check if pixel is ink (all channels below stricter threshold)
 			if r8 < uint8(inkThreshold) && g8 < uint8(inkThreshold) && b8 < uint8(inkThreshold) {
+				x := bounds.Min.X + i
 				if x < minX {
 					minX = x
 				}
@@ -146,7 +142,7 @@ func TrimWhitespace(img image.Image, threshold uint8) (image.Image, image.Rectan
 	maxY = min(bounds.Max.Y, maxY+padding+1)
 
 	trimRect := image.Rect(minX, minY, maxX, maxY)
-	return cropImage(img, trimRect), trimRect
+	return Crop(img, trimRect), trimRect
 }
 
 // MakeTransparent converts white background to transparent with smooth alpha edges.
@@ -163,9 +159,13 @@ func MakeTransparent(img image.Image, threshold uint8) image.Image {
 	// lightest edge pixels get partial transparency.
 	inkOpaque := int(threshold) * 3 / 4
 
+	row := make([]pixel16, bounds.Dx())
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
+		readRow(img, bounds.Min.X, y, row)
+		pix := result.Pix[result.PixOffset(bounds.Min.X, y):]
+		for i, c := range row {
+			// Composite over white so already transparent glyphs keep their background
+			r, g, b := c.r+0xFFFF-c.a, c.g+0xFFFF-c.a, c.b+0xFFFF-c.a
 			r8 := uint8(r >> 8)
 			g8 := uint8(g >> 8)
 			b8 := uint8(b >> 8)
@@ -179,18 +179,19 @@ func MakeTransparent(img image.Image, threshold uint8) image.Image {
 				maxCh = int(b8)
 			}
 
+			out := pix[i*4 : i*4+4 : i*4+4]
 			if maxCh >= int(threshold) {
-				// Light pixel — fully transparent background
-				result.SetNRGBA(x, y, color.NRGBA{R: 0, G: 0, B: 0, A: 0})
+				// Light pixel — fully transparent background (left zero)
+				continue
 			} else if maxCh <= inkOpaque {
 				// Dark pixel — fully opaque ink
-				result.SetNRGBA(x, y, color.NRGBA{R: r8, G: g8, B: b8, A: 255})
+				out[0], out[1], out[2], out[3] = r8, g8, b8, 255
 			} else {
 				// Transition zone — smooth gradient from opaque to transparent
 				// Map [inkOpaque..threshold] → alpha [255..0]
 				span := int(threshold) - inkOpaque
 				alpha := 255 * (int(threshold) - maxCh) / span
-				result.SetNRGBA(x, y, color.NRGBA{R: r8, G: g8, B: b8, A: uint8(alpha)})
+				out[0], out[1], out[2], out[3] = r8, g8, b8, uint8(alpha)
 			}
 		}
 	}
@@ -0,0 +1,335 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+// The pixel loops as they were before they read and wrote pixel slices, kept
+// as the baseline the benchmarks compare with
+
+func oldCrop(img image.Image, rect image.Rectangle) image.Image {
+	bounds := img.Bounds()
+	rect = rect.Intersect(bounds)
+
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			cropped.Set(x-rect.Min.X, y-rect.Min.Y, img.At(x, y))
+		}
+	}
+	return cropped
+}
+
+func oldTrimWhitespace(img image.Image, threshold uint8) (image.Image, image.Rectangle) {
+	bounds := img.Bounds()
+	minX, minY := bounds.Max.X, bounds.Max.Y
+	maxX, maxY := bounds.Min.X, bounds.Min.Y
+
+	inkThreshold := int(threshold) * 3 / 4
+	if inkThreshold > 255 {
+		inkThreshold = 255
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r8 := uint8(r >> 8)
+			g8 := uint8(g >> 8)
+			b8 := uint8(b >> 8)
+
+			if r8 < uint8(inkThreshold) && g8 < uint8(inkThreshold) && b8 < uint8(inkThreshold) {
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+				if y < minY {
+					minY = y
+				}
+				if y > maxY {
+					maxY = y
+				}
+			}
+		}
+	}
+
+	if minX > maxX || minY > maxY {
+		return img, bounds
+	}
+
+	padding := 2
+	minX = max(bounds.Min.X, minX-padding)
+	minY = max(bounds.Min.Y, minY-padding)
+	maxX = min(bounds.Max.X, maxX+padding+1)
+	maxY = min(bounds.Max.Y, maxY+padding+1)
+
+	trimRect := image.Rect(minX, minY, maxX, maxY)
+	return oldCrop(img, trimRect), trimRect
+}
+
+func oldMakeTransparent(img image.Image, threshold uint8) image.Image {
+	bounds := img.Bounds()
+	result := image.NewNRGBA(bounds)
+	inkOpaque := int(threshold) * 3 / 4
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			r, g, b = r+0xFFFF-a, g+0xFFFF-a, b+0xFFFF-a
+			r8 := uint8(r >> 8)
+			g8 := uint8(g >> 8)
+			b8 := uint8(b >> 8)
+
+			maxCh := int(r8)
+			if int(g8) > maxCh {
+				maxCh = int(g8)
+			}
+			if int(b8) > maxCh {
+				maxCh = int(b8)
+			}
+
+			if maxCh >= int(threshold) {
+				result.SetNRGBA(x, y, color.NRGBA{R: 0, G: 0, B: 0, A: 0})
+			} else if maxCh <= inkOpaque {
+				result.SetNRGBA(x, y, color.NRGBA{R: r8, G: g8, B: b8, A: 255})
+			} else {
+				span := int(threshold) - inkOpaque
+				alpha := 255 * (int(threshold) - maxCh) / span
+				result.SetNRGBA(x, y, color.NRGBA{R: r8, G: g8, B: b8, A: uint8(alpha)})
+			}
+		}
+	}
+	return result
+}
+
+// testPixel is a light, noisy page with a block of dark ink and a band of
+// edge tones between them at (x, y), the same for every image type
+func testPixel(rng *rand.Rand, bounds image.Rectangle, x, y int) uint8 {
+	inner := bounds.Inset(min(bounds.Dx(), bounds.Dy()) / 4)
+	switch {
+	case image.Pt(x, y).In(inner.Inset(2)):
+		return uint8(rng.IntN(90))
+	case image.Pt(x, y).In(inner):
+		return uint8(90 + rng.IntN(110))
+	}
+	return uint8(210 + rng.IntN(46))
+}
+
+// testImages builds the image types the fast paths handle, also as sub
+// images whose bounds do not start at (0, 0), and 4:2:0 YCbCr images of odd
+// size and origin where chroma samples cover a partial 2x2 block
+func testImages() []struct {
+	name string
+	img  image.Image
+} {
+	rng := rand.New(rand.NewPCG(1, 2))
+	bounds := image.Rect(0, 0, 41, 37)
+
+	rgba := image.NewRGBA(bounds)
+	nrgba := image.NewNRGBA(bounds)
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := testPixel(rng, bounds, x, y)
+			a := uint8(255 - rng.IntN(3)*60) // Some pixels partly transparent
+			rgba.Set(x, y, color.NRGBA{v, v / 2, uint8(min(255, int(v)+20)), a})
+			nrgba.SetNRGBA(x, y, color.NRGBA{v / 2, v, uint8(rng.IntN(256)), a})
+			gray.SetGray(x, y, color.Gray{v})
+		}
+	}
+
+	ycbcr := func(r image.Rectangle) *image.YCbCr {
+		img := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Y[img.YOffset(x, y)] = testPixel(rng, r, x, y)
+				ci := img.COffset(x, y)
+				img.Cb[ci], img.Cr[ci] = uint8(100+rng.IntN(56)), uint8(100+rng.IntN(56))
+			}
+		}
+		return img
+	}
+	odd := ycbcr(image.Rect(0, 0, 37, 23))
+	sub := image.Rect(5, 3, 36, 32)
+
+	return []struct {
+		name string
+		img  image.Image
+	}{
+		{"RGBA", rgba},
+		{"NRGBA", nrgba},
+		{"Gray", gray},
+		{"YCbCr", ycbcr(bounds)},
+		{"RGBA sub", rgba.SubImage(sub)},
+		{"NRGBA sub", nrgba.SubImage(sub)},
+		{"Gray sub", gray.SubImage(sub)},
+		{"YCbCr sub", ycbcr(bounds).SubImage(sub)},
+		{"YCbCr odd", odd},
+		{"YCbCr odd sub", odd.SubImage(image.Rect(3, 1, 34, 22))},
+		{"YCbCr odd origin", ycbcr(image.Rect(3, 1, 40, 24))},
+	}
+}
+
+// sameImage reports the first pixel where got differs from want, both
+// compared as 8-bit premultiplied RGBA like the old loops stored them
+func sameImage(t *testing.T, got, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.RGBAModel.Convert(got.At(x, y))
+			w := color.RGBAModel.Convert(want.At(x, y))
+			if g != w {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestFastPathsMatchOldLoops(t *testing.T) {
+	for _, tc := range testImages() {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.img.Bounds()
+			for _, rect := range []image.Rectangle{b, b.Inset(4).Add(image.Pt(1, 2)), b.Add(image.Pt(-6, 5))} {
+				sameImage(t, Crop(tc.img, rect), oldCrop(tc.img, rect))
+			}
+
+			for _, threshold := range []uint8{100, 160, 230} {
+				got, gotRect := TrimWhitespace(tc.img, threshold)
+				want, wantRect := oldTrimWhitespace(tc.img, threshold)
+				if gotRect != wantRect {
+					t.Fatalf("threshold %d: trim %v, want %v", threshold, gotRect, wantRect)
+				}
+				sameImage(t, got, want)
+
+				transparent := MakeTransparent(tc.img, threshold).(*image.NRGBA)
+				old := oldMakeTransparent(tc.img, threshold).(*image.NRGBA)
+				if transparent.Rect != old.Rect {
+					t.Fatalf("threshold %d: bounds %v, want %v", threshold, transparent.Rect, old.Rect)
+				}
+				for y := old.Rect.Min.Y; y < old.Rect.Max.Y; y++ {
+					for x := old.Rect.Min.X; x < old.Rect.Max.X; x++ {
+						if g, w := transparent.NRGBAAt(x, y), old.NRGBAAt(x, y); g != w {
+							t.Fatalf("threshold %d: pixel (%d, %d) is %v, want %v", threshold, x, y, g, w)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// benchPage draws an A4 page at the given resolution: white paper with the
+// template grid and a dark stroke in every cell
+func benchPage(dpi int) *image.RGBA {
+	px := func(mm float64) int { return int(mm * float64(dpi) / 25.4) }
+	page := image.NewRGBA(image.Rect(0, 0, px(210), px(297)))
+	for i := range page.Pix {
+		page.Pix[i] = 255
+	}
+
+	grid := color.RGBA{200, 200, 200, 255}
+	ink := color.RGBA{25, 30, 90, 255}
+	cellW, cellH := px(22.5), px(26.2)
+	pen := max(1, dpi/60)
+	for row := 0; row < 10; row++ {
+		for col := 0; col < 8; col++ {
+			x0, y0 := px(15)+col*cellW, px(15)+row*cellH
+			for i := 0; i < cellW; i++ {
+				page.SetRGBA(x0+i, y0, grid)
+			}
+			for i := 0; i < cellH; i++ {
+				page.SetRGBA(x0, y0+i, grid)
+			}
+			// A diagonal stroke standing in for a letter
+			for i := cellH / 4; i < cellH*3/4; i++ {
+				for p := 0; p < pen; p++ {
+					page.SetRGBA(x0+cellW/4+i/2+p, y0+i, ink)
+				}
+			}
+		}
+	}
+	return page
+}
+
+// benchPages builds the page in each image type the decoders produce: RGBA
+// and NRGBA from PNG and TIFF, Gray from greyscale scans, YCbCr from JPEG
+func benchPages(b *testing.B, dpi int) []struct {
+	name string
+	img  image.Image
+} {
+	b.Helper()
+	src := benchPage(dpi)
+	convert := func(dst draw.Image) image.Image {
+		draw.Draw(dst, dst.Bounds(), src, image.Point{}, draw.Src)
+		return dst
+	}
+	ycbcr := image.NewYCbCr(src.Bounds(), image.YCbCrSubsampleRatio420)
+	for y := 0; y < src.Bounds().Dy(); y++ {
+		for x := 0; x < src.Bounds().Dx(); x++ {
+			c := src.RGBAAt(x, y)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+			ci := ycbcr.COffset(x, y)
+			ycbcr.Cb[ci], ycbcr.Cr[ci] = cb, cr
+		}
+	}
+
+	return []struct {
+		name string
+		img  image.Image
+	}{
+		{"RGBA", src},
+		{"NRGBA", convert(image.NewNRGBA(src.Bounds()))},
+		{"Gray", convert(image.NewGray(src.Bounds()))},
+		{"YCbCr", ycbcr},
+	}
+}
+
+// benchmarkPage runs the old and the new version of a pixel loop over a
+// whole page at 300 and 600 DPI in every image type
+func benchmarkPage(b *testing.B, before, after func(image.Image)) {
+	for _, dpi := range []int{300, 600} {
+		for _, page := range benchPages(b, dpi) {
+			for _, v := range []struct {
+				name string
+				run  func(image.Image)
+			}{{"old", before}, {"new", after}} {
+				b.Run(fmt.Sprintf("%ddpi/%s/%s", dpi, page.name, v.name), func(b *testing.B) {
+					b.ReportAllocs()
+					for b.Loop() {
+						v.run(page.img)
+					}
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkCrop(b *testing.B) {
+	benchmarkPage(b,
+		func(img image.Image) { oldCrop(img, img.Bounds()) },
+		func(img image.Image) { Crop(img, img.Bounds()) })
+}
+
+func BenchmarkTrimWhitespace(b *testing.B) {
+	benchmarkPage(b,
+		func(img image.Image) { oldTrimWhitespace(img, 160) },
+		func(img image.Image) { TrimWhitespace(img, 160) })
+}
+
+func BenchmarkMakeTransparent(b *testing.B) {
+	benchmarkPage(b,
+		func(img image.Image) { oldMakeTransparent(img, 160) },
+		func(img image.Image) { MakeTransparent(img, 160) })
+}
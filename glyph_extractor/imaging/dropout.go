@@ -0,0 +1,100 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// DropoutColors are grid colours that print light enough to write over.
+// Red and green are far in hue from blue and black pens; cyan is only about
+// 30° from blue ballpoint ink, which RemoveDropout tells apart by its darkness.
+var DropoutColors = map[string]color.NRGBA{
+	"red":   {255, 130, 130, 255},
+	"cyan":  {110, 210, 240, 255},
+	"green": {130, 220, 130, 255},
+}
+
+// ParseDropoutColor accepts a DropoutColors name or a #RRGGBB value
+func ParseDropoutColor(s string) (color.NRGBA, error) {
+	if c, ok := DropoutColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	c, err := ParseColor(s)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	if c == nil {
+		return color.NRGBA{}, fmt.Errorf("invalid dropout colour %q", s)
+	}
+	return color.NRGBAModel.Convert(c).(color.NRGBA), nil
+}
+
+// rgbToHSV converts a colour to hue in degrees (0-360), saturation and value (0-1)
+func rgbToHSV(r, g, b uint8) (h, s, v float64) {
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	hi := math.Max(rf, math.Max(gf, bf))
+	lo := math.Min(rf, math.Min(gf, bf))
+	v = hi
+	if hi == 0 {
+		return 0, 0, v
+	}
+	s = (hi - lo) / hi
+	if hi == lo {
+		return 0, s, v
+	}
+
+	switch hi {
+	case rf:
+		h = 60 * math.Mod((gf-bf)/(hi-lo), 6)
+	case gf:
+		h = 60 * ((bf-rf)/(hi-lo) + 2)
+	default:
+		h = 60 * ((rf-gf)/(hi-lo) + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// Dropout pixels must be at least this saturated and light, and at least
+// dropoutValueRatio as light as the dropout colour itself; darker pixels are
+// ink, even where a stroke crosses a grid line or a scanner shifts the hue of
+// blue ink towards a cyan grid
+const (
+	dropoutMinSaturation = 0.15
+	dropoutMinValue      = 0.35
+	dropoutValueRatio    = 0.8
+)
+
+// RemoveDropout whitens every pixel whose hue is within tolerance degrees of
+// the dropout colour and that is about as light as it, so the printed grid
+// vanishes before thresholding
+func RemoveDropout(img image.Image, dropout color.NRGBA, tolerance float64) *image.NRGBA {
+	hue, _, value := rgbToHSV(dropout.R, dropout.G, dropout.B)
+	minValue := max(dropoutMinValue, dropoutValueRatio*value)
+	bounds := img.Bounds()
+	result := image.NewNRGBA(bounds)
+	white := color.NRGBA{255, 255, 255, 255}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			h, s, v := rgbToHSV(c.R, c.G, c.B)
+
+			// Hue distance around the colour wheel
+			diff := math.Abs(h - hue)
+			diff = math.Min(diff, 360-diff)
+
+			if s >= dropoutMinSaturation && v >= minValue && diff <= tolerance {
+				result.SetNRGBA(x, y, white)
+			} else {
+				result.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return result
+}
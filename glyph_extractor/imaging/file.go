@@ -0,0 +1,34 @@
+// Package imaging holds the pixel operations of the extractor: cropping and
+// trimming cells, ink detection and recolouring, dropout removal, stroke
+// measurement and page orientation.
+package imaging
+
+import (
+	"image"
+	"image/png"
+	"os"
+)
+
+// LoadImage loads a single image, detecting the format (PNG, JPEG, TIFF, BMP
+// or WebP) from its content
+func LoadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// SavePNG saves an image as PNG
+func SavePNG(img image.Image, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return png.Encode(file, img)
+}
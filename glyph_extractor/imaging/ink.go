@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// InkMode selects how the ink colour of extracted glyphs is written
+type InkMode string
+
+const (
+	InkOriginal InkMode = "original" // Keep the scanned colour of every pixel
+	InkMask     InkMode = "mask"     // White ink, only the alpha carries the shape
+	InkRecolor  InkMode = "recolor"  // One flat colour, alpha from MakeTransparent
+)
+
+// ParseInkMode validates an ink mode flag value
+func ParseInkMode(s string) (InkMode, error) {
+	switch mode := InkMode(s); mode {
+	case InkOriginal, InkMask, InkRecolor:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown ink mode %q (want original, mask or recolor)", s)
+}
+
+// DominantInkColor estimates the pen colour of a scanned page: the most common
+// colour among the pixels dark enough to be ink. Light grid lines and labels
+// of the printed template are excluded by the threshold.
+func DominantInkColor(img image.Image, threshold uint8) color.NRGBA {
+	inkMax := uint32(threshold) * 3 / 4 << 8
+	b := img.Bounds()
+
+	// Histogram with 4 bits per channel; the winning bin is averaged exactly
+	type bin struct{ r, g, b, n uint64 }
+	bins := make(map[uint32]*bin)
+	var best *bin
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x += 2 {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 || max(r, g, bl) >= inkMax {
+				continue
+			}
+			key := (r>>12)<<8 | (g>>12)<<4 | bl>>12
+			h := bins[key]
+			if h == nil {
+				h = &bin{}
+				bins[key] = h
+			}
+			h.r += uint64(r >> 8)
+			h.g += uint64(g >> 8)
+			h.b += uint64(bl >> 8)
+			h.n++
+			if best == nil || h.n > best.n {
+				best = h
+			}
+		}
+	}
+
+	if best == nil {
+		return color.NRGBA{0, 0, 0, 255}
+	}
+	return color.NRGBA{uint8(best.r / best.n), uint8(best.g / best.n), uint8(best.b / best.n), 255}
+}
+
+// ApplyInk rewrites the colour of a transparent glyph, keeping its alpha.
+// Mask mode makes the ink white so it can be tinted by multiplication.
+func ApplyInk(img image.Image, mode InkMode, ink color.NRGBA) image.Image {
+	if mode == InkOriginal {
+		return img
+	}
+	if mode == InkMask {
+		ink = color.NRGBA{255, 255, 255, 255}
+	}
+
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			out.SetNRGBA(x, y, color.NRGBA{ink.R, ink.G, ink.B, uint8(uint32(ink.A) * (a >> 8) / 255)})
+		}
+	}
+	return out
+}
+
+// ColorHex formats a colour as #RRGGBB
+func ColorHex(c color.NRGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// InkColor returns the average colour of a glyph's ink
+func InkColor(img image.Image) color.NRGBA {
+	mask := NewMask(img, GlyphInkThreshold)
+	b := mask.Bounds
+
+	var sr, sg, sb, n uint64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !mask.At(x, y) {
+				continue
+			}
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			sr += uint64(c.R)
+			sg += uint64(c.G)
+			sb += uint64(c.B)
+			n++
+		}
+	}
+	if n == 0 {
+		return color.NRGBA{0, 0, 0, 255}
+	}
+	return color.NRGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 255}
+}
+
+// ParseColor parses "transparent", a few colour names or a #RRGGBB / #RRGGBBAA hex value
+func ParseColor(s string) (color.Color, error) {
+	switch strings.ToLower(s) {
+	case "", "transparent", "none":
+		return nil, nil
+	case "white":
+		return color.NRGBA{255, 255, 255, 255}, nil
+	case "black":
+		return color.NRGBA{0, 0, 0, 255}, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return nil, fmt.Errorf("invalid colour %q (want #RRGGBB or #RRGGBBAA)", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid colour %q: %w", s, err)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xFF
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
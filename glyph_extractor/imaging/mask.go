@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"image"
+)
+
+// GlyphInkThreshold is the lightness below which a glyph pixel counts as ink
+const GlyphInkThreshold = 160
+
+// Mask marks the ink pixels of an image: at least half opaque and darker
+// than threshold. Works for transparent glyphs and opaque scans alike; glyphs
+// with transparency are judged by alpha alone, so recoloured and mask glyphs
+// of any colour are found too.
+type Mask struct {
+	Bounds image.Rectangle
+	ink    []bool
+}
+
+// NewMask marks the ink pixels of img, judging opaque images by threshold
+func NewMask(img image.Image, threshold uint8) *Mask {
+	bounds := img.Bounds()
+	m := &Mask{Bounds: bounds, ink: make([]bool, bounds.Dx()*bounds.Dy())}
+	transparent := hasTransparency(img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			if transparent {
+				m.ink[(y-bounds.Min.Y)*bounds.Dx()+(x-bounds.Min.X)] = true
+				continue
+			}
+			// Un-premultiply to judge the ink colour itself
+			lightness := max(r, g, b) * 0xFFFF / a
+			if lightness < uint32(threshold)<<8 {
+				m.ink[(y-bounds.Min.Y)*bounds.Dx()+(x-bounds.Min.X)] = true
+			}
+		}
+	}
+	return m
+}
+
+// hasTransparency reports whether any pixel of img is not fully opaque
+func hasTransparency(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xFFFF {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// At reports whether the pixel at image coordinates (x, y) is ink
+func (m *Mask) At(x, y int) bool {
+	if !image.Pt(x, y).In(m.Bounds) {
+		return false
+	}
+	return m.ink[(y-m.Bounds.Min.Y)*m.Bounds.Dx()+(x-m.Bounds.Min.X)]
+}
+
+// InkBounds returns the bounding box of all ink pixels, or an empty rectangle
+func (m *Mask) InkBounds() image.Rectangle {
+	var bounds image.Rectangle
+	for y := m.Bounds.Min.Y; y < m.Bounds.Max.Y; y++ {
+		for x := m.Bounds.Min.X; x < m.Bounds.Max.X; x++ {
+			if m.At(x, y) {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+// Component is one 8-connected blob of ink
+type Component struct {
+	Bounds image.Rectangle
+	Area   int
+}
+
+// Components finds the 8-connected ink blobs of the mask
+func (m *Mask) Components() []Component {
+	_, components := m.Label()
+	return components
+}
+
+// Label finds the 8-connected ink blobs of the mask like Components and also
+// returns the blob of every pixel, row by row over the mask bounds: its index
+// in components, or -1 for no ink
+func (m *Mask) Label() (labels []int, components []Component) {
+	w, h := m.Bounds.Dx(), m.Bounds.Dy()
+	labels = make([]int, len(m.ink))
+	for i := range labels {
+		labels[i] = -1
+	}
+	var stack []int
+
+	for start := range m.ink {
+		if !m.ink[start] || labels[start] >= 0 {
+			continue
+		}
+
+		c := Component{}
+		label := len(components)
+		labels[start] = label
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			c.Bounds = c.Bounds.Union(image.Rect(x, y, x+1, y+1))
+			c.Area++
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					j := ny*w + nx
+					if m.ink[j] && labels[j] < 0 {
+						labels[j] = label
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+
+		c.Bounds = c.Bounds.Add(m.Bounds.Min)
+		components = append(components, c)
+	}
+	return labels, components
+}
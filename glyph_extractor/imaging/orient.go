@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"image"
+)
+
+// Rotate turns an image clockwise by a multiple of 90 degrees
+func Rotate(img image.Image, degrees int) image.Image {
+	degrees = (degrees%360 + 360) % 360
+	if degrees == 0 || degrees%90 != 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out *image.RGBA
+	if degrees == 180 {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	} else {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				out.Set(h-1-y, x, c)
+			case 180:
+				out.Set(w-1-x, h-1-y, c)
+			case 270:
+				out.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return out
+}
+
+// Orient undoes a TIFF/EXIF orientation (1-8) so the page is upright.
+// The mirrored orientations are a horizontal flip followed by a rotation.
+func Orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return flip(img)
+	case 3:
+		return Rotate(img, 180)
+	case 4:
+		return Rotate(flip(img), 180)
+	case 5:
+		return Rotate(flip(img), 270)
+	case 6:
+		return Rotate(img, 90)
+	case 7:
+		return Rotate(flip(img), 90)
+	case 8:
+		return Rotate(img, 270)
+	}
+	return img
+}
+
+// flip mirrors an image horizontally
+func flip(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(w-1-x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Chamfer distance weights for straight and diagonal steps; dividing by
+// chamferStraight gives distances in pixels
+const (
+	chamferStraight = 3
+	chamferDiagonal = 4
+)
+
+// chamferInf marks pixels that have not been reached yet
+const chamferInf = 1 << 30
+
+// distanceTransform returns, for every ink pixel of the mask, the chamfer
+// distance in pixels to the nearest pixel without ink. Pixels without ink are 0.
+func distanceTransform(m *Mask) []float64 {
+	return chamfer(m.ink, m.Bounds.Dx(), m.Bounds.Dy(), 0)
+}
+
+// InkDistance returns, for every pixel of the mask row by row, the chamfer
+// distance in pixels to the nearest ink. Ink pixels are 0; a mask without
+// ink is +Inf everywhere.
+func InkDistance(m *Mask) []float64 {
+	blank := make([]bool, len(m.ink))
+	for i, ink := range m.ink {
+		blank[i] = !ink
+	}
+	return chamfer(blank, m.Bounds.Dx(), m.Bounds.Dy(), chamferInf)
+}
+
+// chamfer computes the two-pass chamfer distance from every set pixel to the
+// nearest unset one. outside is the distance assumed beyond the image edge.
+func chamfer(set []bool, w, h, outside int) []float64 {
+	dist := make([]int, w*h)
+	for i, s := range set {
+		if s {
+			dist[i] = chamferInf
+		}
+	}
+
+	get := func(x, y int) int {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return outside
+		}
+		return dist[y*w+x]
+	}
+
+	// Forward pass: top-left neighbours
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if dist[i] == 0 {
+				continue
+			}
+			d := dist[i]
+			d = min(d, get(x-1, y)+chamferStraight, get(x, y-1)+chamferStraight)
+			d = min(d, get(x-1, y-1)+chamferDiagonal, get(x+1, y-1)+chamferDiagonal)
+			dist[i] = d
+		}
+	}
+
+	// Backward pass: bottom-right neighbours
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			i := y*w + x
+			if dist[i] == 0 {
+				continue
+			}
+			d := dist[i]
+			d = min(d, get(x+1, y)+chamferStraight, get(x, y+1)+chamferStraight)
+			d = min(d, get(x+1, y+1)+chamferDiagonal, get(x-1, y+1)+chamferDiagonal)
+			dist[i] = d
+		}
+	}
+
+	result := make([]float64, len(dist))
+	for i, d := range dist {
+		if d >= chamferInf {
+			result[i] = math.Inf(1)
+			continue
+		}
+		result[i] = float64(d) / chamferStraight
+	}
+	return result
+}
+
+// StrokeWidth estimates the pen width of a glyph in pixels from the distance
+// transform: the median distance along the stroke centre lines, doubled
+func StrokeWidth(m *Mask) float64 {
+	w, h := m.Bounds.Dx(), m.Bounds.Dy()
+	dist := distanceTransform(m)
+
+	var ridge []float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if d := dist[y*w+x]; d > 0 && isRidge(dist, w, h, x, y) {
+				ridge = append(ridge, d)
+			}
+		}
+	}
+
+	if len(ridge) == 0 {
+		return 0
+	}
+	sort.Float64s(ridge)
+	return max(1, 2*ridge[len(ridge)/2]-1)
+}
+
+// isRidge reports whether the pixel at (x, y) lies on a stroke centre line:
+// at least as far from the edge as all its neighbours
+func isRidge(dist []float64, w, h, x, y int) bool {
+	d := dist[y*w+x]
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			nx, ny := x+dx, y+dy
+			if nx >= 0 && ny >= 0 && nx < w && ny < h && dist[ny*w+nx] > d {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NormalizeStroke thins or thickens a glyph towards the target pen width by
+// eroding or dilating its ink. Dilation grows the image by the returned number
+// of pixels on every side so no ink is clipped.
+func NormalizeStroke(img image.Image, target float64) (image.Image, int) {
+	mask := NewMask(img, GlyphInkThreshold)
+	current := StrokeWidth(mask)
+	if current == 0 || target <= 0 {
+		return img, 0
+	}
+
+	radius := int(math.Round((target - current) / 2))
+	if radius == 0 {
+		return img, 0
+	}
+
+	b := img.Bounds()
+	background := color.NRGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.NRGBA)
+
+	if radius < 0 {
+		// Erode: clear the ink closest to the stroke edges, but never the
+		// centre line so thin strokes don't break apart
+		dist := distanceTransform(mask)
+		w, h := b.Dx(), b.Dy()
+		out := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				d := dist[y*w+x]
+				if d > 0 && d <= float64(-radius) && !isRidge(dist, w, h, x, y) {
+					out.SetNRGBA(x, y, background)
+				} else {
+					out.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+				}
+			}
+		}
+		return out, 0
+	}
+
+	// Dilate: paint the ink colour over everything within radius of the ink
+	ink := InkColor(img)
+	grown := image.Rect(0, 0, b.Dx()+2*radius, b.Dy()+2*radius)
+	out := image.NewNRGBA(grown)
+	for y := 0; y < grown.Dy(); y++ {
+		for x := 0; x < grown.Dx(); x++ {
+			out.SetNRGBA(x, y, background)
+		}
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.Set(x+radius, y+radius, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	padded := NewMask(out, GlyphInkThreshold)
+	dist := InkDistance(padded)
+	for y := 0; y < grown.Dy(); y++ {
+		for x := 0; x < grown.Dx(); x++ {
+			if d := dist[y*grown.Dx()+x]; d > 0 && d <= float64(radius) {
+				out.SetNRGBA(x, y, ink)
+			}
+		}
+	}
+	return out, radius
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+)
+
+// KerningConfig holds the auto-kerning options
+type KerningConfig struct {
+	SmoothRatio float64 // Vertical smoothing of the edge profiles, fraction of the line box
+	DepthRatio  float64 // Deepest gap counted per row, fraction of the line box
+	MinGapRatio float64 // Closest two glyphs may get, fraction of the reference gap
+	MaxRatio    float64 // Largest kerning amount, fraction of the line box
+	MinAmount   int     // Amounts smaller than this many pixels are dropped
+}
+
+// DefaultKerningConfig returns the default auto-kerning options
+func DefaultKerningConfig() KerningConfig {
+	return KerningConfig{
+		SmoothRatio: 0.08,
+		DepthRatio:  0.15,
+		MinGapRatio: 0.4,
+		MaxRatio:    0.25,
+		MinAmount:   2,
+	}
+}
+
+// glyphProfile holds the left and right ink edges of a glyph at every height.
+// Rows are relative to the baseline; edges are relative to the pen position.
+type glyphProfile struct {
+	top   int   // First row with ink, relative to the baseline
+	left  []int // Leftmost ink column per row, -1 without ink
+	right []int // One past the rightmost ink column per row, -1 without ink
+	width int   // Advance width
+}
+
+// newGlyphProfile measures a glyph's edge contours, widening each row by the
+// rows within smooth pixels so thin gaps between strokes don't count
+func newGlyphProfile(glyph *manifest.Glyph, smooth int) *glyphProfile {
+	mask := imaging.NewMask(glyph.Image, imaging.GlyphInkThreshold)
+	b := mask.Bounds
+
+	rows := b.Dy()
+	left := make([]int, rows)
+	right := make([]int, rows)
+	for y := 0; y < rows; y++ {
+		left[y], right[y] = -1, -1
+		for x := 0; x < b.Dx(); x++ {
+			if mask.At(b.Min.X+x, b.Min.Y+y) {
+				if left[y] < 0 {
+					left[y] = x
+				}
+				right[y] = x + 1
+			}
+		}
+	}
+
+	p := &glyphProfile{
+		top:   -glyph.Metrics.Baseline - smooth,
+		left:  make([]int, rows+2*smooth),
+		right: make([]int, rows+2*smooth),
+		width: glyph.Metrics.Width,
+	}
+	for i := range p.left {
+		p.left[i], p.right[i] = -1, -1
+		for y := max(0, i-2*smooth); y <= min(rows-1, i); y++ {
+			if left[y] < 0 {
+				continue
+			}
+			if p.left[i] < 0 || left[y] < p.left[i] {
+				p.left[i] = left[y]
+			}
+			if right[y] > p.right[i] {
+				p.right[i] = right[y]
+			}
+		}
+	}
+	return p
+}
+
+// pairGap measures the space between two glyphs set side by side without
+// kerning. It returns the mean gap over the shared rows, with each row capped
+// at depth, and the smallest gap on any row.
+func pairGap(l, r *glyphProfile, depth float64) (mean, closest float64, ok bool) {
+	from := max(l.top, r.top)
+	to := min(l.top+len(l.left), r.top+len(r.left))
+
+	sum, rows := 0.0, 0
+	closest = math.Inf(1)
+	for y := from; y < to; y++ {
+		lr := l.right[y-l.top]
+		rl := r.left[y-r.top]
+		if lr < 0 || rl < 0 {
+			continue
+		}
+		gap := float64(l.width-lr) + float64(rl)
+		closest = math.Min(closest, gap)
+		sum += math.Min(gap, depth)
+		rows++
+	}
+
+	if rows == 0 {
+		return 0, 0, false
+	}
+	return sum / float64(rows), closest, true
+}
+
+// referencePairs set the spacing every other pair is matched against
+var referencePairs = [][2]string{{"n", "n"}, {"o", "o"}, {"n", "o"}, {"o", "n"}, {"H", "H"}, {"O", "O"}}
+
+// AutoKern derives a kerning table for every pair of charset glyphs in the set
+// by matching their optical gap to the gap of straight-sided reference pairs
+func AutoKern(set *manifest.GlyphSet, config KerningConfig) []manifest.KerningPair {
+	lineBox, _ := set.LineBox()
+	smooth := int(config.SmoothRatio * float64(lineBox) / 2)
+	depth := config.DepthRatio * float64(lineBox)
+
+	keys := set.Keys()
+	profiles := make(map[string]*glyphProfile, len(keys))
+	for _, key := range keys {
+		profiles[key] = newGlyphProfile(set.Glyphs[key], smooth)
+	}
+
+	// The reference gap is what well spaced straight and round letters look like
+	var refs []float64
+	for _, pair := range referencePairs {
+		l, r := profiles[pair[0]], profiles[pair[1]]
+		if l == nil || r == nil {
+			continue
+		}
+		if mean, _, ok := pairGap(l, r, depth); ok {
+			refs = append(refs, mean)
+		}
+	}
+	if len(refs) == 0 {
+		for _, l := range keys {
+			for _, r := range keys {
+				if mean, _, ok := pairGap(profiles[l], profiles[r], depth); ok {
+					refs = append(refs, mean)
+				}
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	sort.Float64s(refs)
+	reference := refs[len(refs)/2]
+
+	minGap := config.MinGapRatio * reference
+	limit := config.MaxRatio * float64(lineBox)
+
+	var pairs []manifest.KerningPair
+	for _, left := range keys {
+		for _, right := range keys {
+			mean, closest, ok := pairGap(profiles[left], profiles[right], depth)
+			if !ok {
+				continue
+			}
+
+			amount := reference - mean
+			// Never pull glyphs closer than the minimum gap on any row
+			if closest+amount < minGap {
+				amount = math.Min(0, minGap-closest)
+			}
+			amount = math.Max(-limit, math.Min(limit, amount))
+
+			rounded := int(math.Round(amount))
+			if rounded > -config.MinAmount && rounded < config.MinAmount {
+				continue
+			}
+			pairs = append(pairs, manifest.KerningPair{Left: left, Right: right, Amount: rounded})
+		}
+	}
+	return pairs
+}
+
+// runKern implements the kern subcommand
+func runKern(args []string) error {
+	config := DefaultKerningConfig()
+
+	fs := flag.NewFlagSet("kern", flag.ExitOnError)
+	fs.Float64Var(&config.SmoothRatio, "smooth", config.SmoothRatio, "Vertical profile smoothing as fraction of the line height")
+	fs.Float64Var(&config.DepthRatio, "depth", config.DepthRatio, "Deepest gap counted per row as fraction of the line height")
+	fs.Float64Var(&config.MinGapRatio, "min-gap", config.MinGapRatio, "Closest allowed gap as fraction of the reference gap")
+	fs.Float64Var(&config.MaxRatio, "max", config.MaxRatio, "Largest kerning amount as fraction of the line height")
+	fs.IntVar(&config.MinAmount, "min-amount", config.MinAmount, "Drop kerning amounts smaller than this many pixels")
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor kern [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	set, err := manifest.LoadGlyphSet(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Analysing %d glyphs\n", len(set.Glyphs))
+	set.Manifest.Kerning = AutoKern(set, config)
+
+	if err := manifest.Write(&set.Manifest, manifestPath); err != nil {
+		return fmt.Errorf("writing JSON: %w", err)
+	}
+
+	fmt.Printf("Written %d kerning pairs to %s\n", len(set.Manifest.Kerning), manifestPath)
+	return nil
+}
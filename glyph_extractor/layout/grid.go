@@ -0,0 +1,93 @@
+// Package layout describes the cell grid of the handwriting template and cuts
+// cells out of scanned pages.
+package layout
+
+import (
+	"image"
+
+	"glyph_extractor/imaging"
+)
+
+// BaselineRatio is the position of the template's baseline guide within a cell,
+// as a fraction of the cell height measured from the top
+const BaselineRatio = 0.75
+
+// GridConfig holds the configuration for the grid extraction
+type GridConfig struct {
+	CellWidthMM  float64 // Cell width in mm (22.5)
+	CellHeightMM float64 // Cell height in mm (26.2)
+	Columns      int     // Number of columns (8)
+	Rows         int     // Number of rows (10)
+	DPI          int     // Scanner DPI (default 300)
+	MarginTopMM  float64 // Top margin in mm
+	MarginLeftMM float64 // Left margin in mm
+}
+
+// DefaultConfig returns the default grid configuration
+func DefaultConfig() GridConfig {
+	return GridConfig{
+		CellWidthMM:  22.5,
+		CellHeightMM: 26.2,
+		Columns:      8,
+		Rows:         10,
+		DPI:          300,
+		MarginTopMM:  10.0,  // Default 10mm top margin
+		MarginLeftMM: 10.0,  // Default 10mm left margin
+	}
+}
+
+// MMToPixels converts millimeters to pixels based on DPI
+func (c GridConfig) MMToPixels(mm float64) int {
+	// 1 inch = 25.4 mm
+	inches := mm / 25.4
+	return int(inches * float64(c.DPI))
+}
+
+// CellWidthPx returns cell width in pixels
+func (c GridConfig) CellWidthPx() int {
+	return c.MMToPixels(c.CellWidthMM)
+}
+
+// CellHeightPx returns cell height in pixels
+func (c GridConfig) CellHeightPx() int {
+	return c.MMToPixels(c.CellHeightMM)
+}
+
+// MarginTopPx returns top margin in pixels
+func (c GridConfig) MarginTopPx() int {
+	return c.MMToPixels(c.MarginTopMM)
+}
+
+// MarginLeftPx returns left margin in pixels
+func (c GridConfig) MarginLeftPx() int {
+	return c.MMToPixels(c.MarginLeftMM)
+}
+
+// BaselinePx returns the baseline position within a cell in pixels
+func (c GridConfig) BaselinePx() int {
+	return c.MMToPixels(c.CellHeightMM * BaselineRatio)
+}
+
+// CellsPerPage returns the number of cells per page
+func (c GridConfig) CellsPerPage() int {
+	return c.Columns * c.Rows
+}
+
+// CellRect returns the rectangle of the cell at the given row and column in
+// page pixels
+func (c GridConfig) CellRect(row, col int) image.Rectangle {
+	cellW := c.CellWidthPx()
+	cellH := c.CellHeightPx()
+	marginTop := c.MarginTopPx()
+	marginLeft := c.MarginLeftPx()
+
+	x := marginLeft + col*cellW
+	y := marginTop + row*cellH
+
+	return image.Rect(x, y, x+cellW, y+cellH)
+}
+
+// ExtractCell extracts a single cell from the image at the given row and column
+func (c GridConfig) ExtractCell(img image.Image, row, col int) image.Image {
+	return imaging.Crop(img, c.CellRect(row, col))
+}
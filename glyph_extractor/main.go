@@ -1,244 +1,401 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
+	"image/color"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"golang.org/x/text/unicode/norm"
-)
 
-// GlyphsJSON represents the output JSON structure
-type GlyphsJSON struct {
-	Version  int               `json:"version"`
-	CellSize CellSize          `json:"cellSize"`
-	Glyphs   map[string]string `json:"glyphs"`
-}
+	"glyph_extractor/charset"
+	"glyph_extractor/extract"
+	"glyph_extractor/imaging"
+	"glyph_extractor/layout"
+	"glyph_extractor/manifest"
+	"glyph_extractor/reference"
+	"glyph_extractor/scan"
+	"glyph_extractor/template"
+)
 
-type CellSize struct {
-	Width  float64 `json:"width"`
-	Height float64 `json:"height"`
+// errUsage is returned by a command that printed its usage because of
+// missing arguments
+var errUsage = errors.New("usage")
+
+// commands are the subcommands, by name; anything else is an extraction
+var commands = map[string]func(args []string) error{
+	"template":  runTemplate,  // Generates the template PDF
+	"reprocess": runReprocess, // Applies transparency to existing glyph PNGs
+	"rename":    runRename,
+	"atlas":     runAtlas,   // Packs an extracted glyph set into sprite sheets
+	"bmfont":    runBMFont,  // AngelCode bitmap font export
+	"render":    runRender,  // Draws text with an extracted glyph set
+	"kern":      runKern,    // Derives a kerning table from the glyph shapes
+	"batch":     runBatch,   // One glyph set per writer from a directory of scans
+	"pairs":     runPairs,   // Measures spacing from the kerning pairs sheet
+	"preview":   runPreview, // Contact sheet of a glyph set for proofreading
+	"report":    runReport,  // Single-file HTML report for approving a set
 }
 
 func main() {
-	// Check for template command first
-	if len(os.Args) > 1 && os.Args[1] == "template" {
-		outputPath := "template.pdf"
-		if len(os.Args) > 2 {
-			outputPath = os.Args[2]
-		}
-		fmt.Printf("Generating template: %s\n", outputPath)
-		if err := generateTemplate(outputPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println("Done!")
-		return
+	run := runExtract
+	args := os.Args[1:]
+	if len(args) > 0 && commands[args[0]] != nil {
+		run, args = commands[args[0]], args[1:]
 	}
 
-	// Check for reprocess command — applies transparency to existing glyph PNGs
-	if len(os.Args) > 1 && os.Args[1] == "reprocess" {
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: glyph_extractor reprocess <glyphs_dir> [threshold]")
-			os.Exit(1)
-		}
-		dir := os.Args[2]
-		thresh := 200
-		if len(os.Args) > 3 {
-			fmt.Sscanf(os.Args[3], "%d", &thresh)
-		}
-		if err := reprocessGlyphs(dir, uint8(thresh)); err != nil {
+	if err := run(args); err != nil {
+		if err != errUsage {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
 		}
-		return
+		os.Exit(1)
 	}
+}
 
-	// Check for rename command
-	if len(os.Args) > 1 && os.Args[1] == "rename" {
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: glyph_extractor rename <glyphs_dir>")
-			os.Exit(1)
-		}
-		if err := renameGlyphs(os.Args[2]); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+// runTemplate implements the template subcommand
+func runTemplate(args []string) error {
+	fs := flag.NewFlagSet("template", flag.ExitOnError)
+	pairs := fs.Bool("pairs", false, "Generate the kerning pairs practice sheet")
+	gridColor := fs.String("grid-color", "", "Print the grid in a dropout colour: red, cyan, green or #RRGGBB")
+	fs.Parse(args)
+
+	var dropout *color.NRGBA
+	if *gridColor != "" {
+		c, err := imaging.ParseDropoutColor(*gridColor)
+		if err != nil {
+			return err
 		}
-		return
+		dropout = &c
 	}
 
-	// Parse command line arguments
-	var inputFiles string
-	var outputDir string
-	var dpi int
-	var marginTop float64
-	var marginLeft float64
-	var threshold int
-	var transparent bool
-
-	flag.StringVar(&inputFiles, "input", "", "Input image files (comma-separated, e.g., page1.png,page2.png)")
-	flag.StringVar(&outputDir, "output", "./output", "Output directory")
-	flag.IntVar(&dpi, "dpi", 300, "Scanner DPI")
-	flag.Float64Var(&marginTop, "margin-top", 15.0, "Top margin in mm")
-	flag.Float64Var(&marginLeft, "margin-left", 15.0, "Left margin in mm")
-	flag.IntVar(&threshold, "threshold", 160, "White threshold (0-255)")
-	flag.BoolVar(&transparent, "transparent", true, "Make background transparent")
-	flag.Parse()
-
-	if inputFiles == "" {
+	outputPath := "template.pdf"
+	if fs.NArg() > 0 {
+		outputPath = fs.Arg(0)
+	}
+	sheet := template.CharsetSheet
+	if *pairs {
+		sheet = template.PairsSheet
+	}
+	fmt.Printf("Generating template: %s\n", outputPath)
+	if err := template.Generate(outputPath, sheet, dropout); err != nil {
+		return err
+	}
+	fmt.Println("Done!")
+	return nil
+}
+
+// runReprocess implements the reprocess subcommand
+func runReprocess(args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	strokeWidth := fs.Float64("stroke-width", 0, "Normalize the pen width to this many pixels (0 = keep)")
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor reprocess [options] <glyphs_dir> [threshold]")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	thresh := 200
+	if fs.NArg() > 1 {
+		fmt.Sscanf(fs.Arg(1), "%d", &thresh)
+	}
+	return reprocessGlyphs(fs.Arg(0), uint8(thresh), *strokeWidth)
+}
+
+// runRename implements the rename subcommand
+func runRename(args []string) error {
+	if len(args) < 1 {
+		fmt.Println("Usage: glyph_extractor rename <glyphs_dir>")
+		return errUsage
+	}
+	return renameGlyphs(args[0])
+}
+
+// runExtract implements the default command: extracting the glyphs of one
+// set of template scans
+func runExtract(args []string) error {
+	fs := flag.NewFlagSet("glyph_extractor", flag.ExitOnError)
+	inputFiles := fs.String("input", "", "Input scans: PNG, JPEG, TIFF, BMP, WebP or PDF (comma-separated, e.g., page1.png,page2.png)")
+	outputDir := fs.String("output", "./output", "Output directory")
+	extractFlags := addExtractFlags(fs)
+	fs.Usage = func() {
 		fmt.Println("Usage:")
-		fmt.Println("  glyph_extractor template [output.pdf]     - Generate template PDF")
+		fmt.Println("  glyph_extractor template [-pairs] [-grid-color red] [output.pdf] - Generate template PDF")
+		fmt.Println("  glyph_extractor atlas [options] <dir>     - Pack glyphs into atlas pages")
+		fmt.Println("  glyph_extractor bmfont [options] <dir>    - Export an AngelCode BMFont")
+		fmt.Println("  glyph_extractor render [options] <dir> <text> - Render text to a PNG sticker")
+		fmt.Println("  glyph_extractor kern [options] <dir>      - Add auto-kerning to glyphs.json")
+		fmt.Println("  glyph_extractor pairs --input sheet.png <dir> - Measure kerning from the pairs sheet")
+		fmt.Println("  glyph_extractor preview [options] <dir>   - Draw a contact sheet of all glyphs")
+		fmt.Println("  glyph_extractor report [options] <dir>    - Write an HTML report to approve a glyph set")
+		fmt.Println("  glyph_extractor batch [options] <scans_dir> - Extract a glyph set for every writer")
 		fmt.Println("  glyph_extractor --input page1.png,page2.png [options]")
 		fmt.Println("\nOptions:")
-		flag.PrintDefaults()
-		os.Exit(1)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *inputFiles == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts, rotation, err := extractFlags.options()
+	if err != nil {
+		return err
 	}
+	opts.OutputDir = *outputDir
+	opts.Log = os.Stdout
 
 	// Split input files
-	files := strings.Split(inputFiles, ",")
+	files := strings.Split(*inputFiles, ",")
 	for i := range files {
 		files[i] = strings.TrimSpace(files[i])
 	}
 
-	// Create config
-	config := GridConfig{
-		CellWidthMM:  22.5,
-		CellHeightMM: 26.2,
-		Columns:      8,
-		Rows:         10,
-		DPI:          dpi,
-		MarginTopMM:  marginTop,
-		MarginLeftMM: marginLeft,
+	// Load every page up front; a PDF can hold several template pages
+	pages, err := scan.LoadAll(files, rotation)
+	if err != nil {
+		return err
 	}
+	config := extractFlags.gridConfig(pages, template.DefaultConfig(), os.Stdout)
 
-	// Create output directories
-	glyphsDir := filepath.Join(outputDir, "glyphs")
-	if err := os.MkdirAll(glyphsDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
-		os.Exit(1)
+	// Process images; Ctrl+C stops the workers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	set, err := extractPages(ctx, pages, config, opts)
+	if err != nil {
+		return err
 	}
 
-	// Process images
-	glyphsMap := make(map[string]string)
-	charIndex := 0
-
-	for pageIndex, inputFile := range files {
-		fmt.Printf("Processing page %d: %s\n", pageIndex+1, inputFile)
-
-		img, err := loadImage(inputFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading image %s: %v\n", inputFile, err)
-			os.Exit(1)
+	if err := writeExtraction(set, *outputDir); err != nil {
+		return err
+	}
+	if extractFlags.debug {
+		if err := writeDebugOverlays(pages, set.Cells, config, *outputDir, os.Stdout); err != nil {
+			return err
 		}
+	}
 
-		fmt.Printf("  Image size: %dx%d pixels\n", img.Bounds().Dx(), img.Bounds().Dy())
-		fmt.Printf("  Cell size: %dx%d pixels\n", config.CellWidthPx(), config.CellHeightPx())
-
-		// Extract cells
-		for row := 0; row < config.Rows; row++ {
-			for col := 0; col < config.Columns; col++ {
-				if charIndex >= len(Charset) {
-					fmt.Printf("  Warning: More cells than characters in charset\n")
-					break
-				}
+	printStrokeStats(set.Manifest.Stroke)
+	printCellWarnings(set.Cells)
+	printShifts(set.Manifest.Shifts)
+	fmt.Printf("\nDone! Extracted %d glyphs to %s\n", len(set.Glyphs), *outputDir)
+	fmt.Printf("JSON manifest: %s\n", filepath.Join(*outputDir, "glyphs.json"))
+	fmt.Printf("Cell report: %s\n", filepath.Join(*outputDir, "cells.json"))
+	return extractFlags.check(set)
+}
 
-				char := Charset[charIndex]
-				charIndex++
+// check fails a finished extraction under -strict if any cell was empty or
+// drew a warning
+func (f *extractFlags) check(set *manifest.GlyphSet) error {
+	if !f.strict {
+		return nil
+	}
+	if n := len(set.Manifest.Empty) + len(set.Manifest.Warnings); n > 0 {
+		return fmt.Errorf("%d cells are empty or suspicious (-strict)", n)
+	}
+	return nil
+}
 
-				// Extract cell
-				cell := config.ExtractCell(img, row, col)
+// writeExtraction writes glyphs.json and cells.json of an extracted set into dir
+func writeExtraction(set *manifest.GlyphSet, dir string) error {
+	if err := manifest.Write(&set.Manifest, filepath.Join(dir, "glyphs.json")); err != nil {
+		return fmt.Errorf("writing JSON: %w", err)
+	}
+	if err := manifest.WriteCells(set.Cells, filepath.Join(dir, "cells.json")); err != nil {
+		return fmt.Errorf("writing cell report: %w", err)
+	}
+	return nil
+}
 
-				// Trim whitespace
-				trimmed, _ := TrimWhitespace(cell, uint8(threshold))
+// extractPages extracts the glyphs of loaded scan pages, naming each page in
+// the log by the file it came from
+func extractPages(ctx context.Context, pages []scan.Page, config layout.GridConfig, opts extract.Options) (*manifest.GlyphSet, error) {
+	images := make([]image.Image, len(pages))
+	opts.PageNames = make([]string, len(pages))
+	opts.PageFiles = make([]string, len(pages))
+	opts.PageNumbers = make([]int, len(pages))
+	for i, page := range pages {
+		images[i] = page.Image
+		opts.PageNames[i] = page.Source
+		opts.PageFiles[i], opts.PageNumbers[i] = page.Path, page.Number
+	}
+	return extract.Extract(ctx, images, config, opts)
+}
 
-				// Make transparent if requested
-				var finalImg image.Image
-				if transparent {
-					finalImg = MakeTransparent(trimmed, uint8(threshold))
-				} else {
-					finalImg = trimmed
-				}
+// extractFlags are the extraction options shared by the default command and batch
+type extractFlags struct {
+	fs               *flag.FlagSet
+	dpi              int
+	marginTop        float64
+	marginLeft       float64
+	threshold        int
+	transparent      bool
+	strokeWidth      float64
+	inkMode          string
+	inkColor         string
+	dropout          string
+	dropoutTolerance float64
+	orientation      string
+	jobs             int
+	strict           bool
+	debug            bool
+	reference        string
+}
 
-				// Generate filename
-				filename := CharToFilename(char) + ".png"
-				filepath := filepath.Join(glyphsDir, filename)
+// addScanFlags registers the options for reading scanned template pages on a
+// flag set: resolution, grid position, threshold, dropout and orientation
+func addScanFlags(fs *flag.FlagSet) *extractFlags {
+	f := &extractFlags{fs: fs}
+	fs.IntVar(&f.dpi, "dpi", 300, "Scanner DPI")
+	fs.Float64Var(&f.marginTop, "margin-top", 15.0, "Top margin in mm")
+	fs.Float64Var(&f.marginLeft, "margin-left", 15.0, "Left margin in mm")
+	fs.IntVar(&f.threshold, "threshold", 160, "White threshold (0-255)")
+	fs.StringVar(&f.dropout, "dropout", "", "Remove a grid printed in this colour: red, cyan, green or #RRGGBB")
+	fs.Float64Var(&f.dropoutTolerance, "dropout-tolerance", 25, "Hue distance in degrees still removed by -dropout")
+	fs.StringVar(&f.orientation, "orientation", "auto", "Page orientation: auto (EXIF/TIFF/PDF tag) or 0, 90, 180, 270 to rotate clockwise instead")
+	return f
+}
 
-				// Save image
-				if err := savePNG(finalImg, filepath); err != nil {
-					fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", filepath, err)
-					continue
-				}
+// addExtractFlags registers the extraction options on a flag set
+func addExtractFlags(fs *flag.FlagSet) *extractFlags {
+	f := addScanFlags(fs)
+	fs.BoolVar(&f.transparent, "transparent", true, "Make background transparent")
+	fs.Float64Var(&f.strokeWidth, "stroke-width", 0, "Normalize the pen width to this many pixels (0 = keep)")
+	fs.StringVar(&f.inkMode, "ink", "original", "Ink colour: original, mask (white, alpha only) or recolor")
+	fs.StringVar(&f.inkColor, "ink-color", "", "Colour for -ink recolor as #RRGGBB (default: dominant ink colour of each page)")
+	fs.IntVar(&f.jobs, "j", runtime.NumCPU(), "Number of cells extracted in parallel")
+	fs.BoolVar(&f.strict, "strict", false, "Fail if a cell is empty or its glyph looks wrong")
+	fs.StringVar(&f.reference, "reference", defaultFontPath, "Font the glyphs are compared with to find characters written in the wrong cell (\"\" to skip)")
+	fs.BoolVar(&f.debug, "debug", false, "Also write every page with the cells, cuts, baselines and labels drawn on top to <output>/debug")
+	return f
+}
 
-				// Add to map
-				glyphsMap[string(char)] = filename
+// options validates the flags and returns the extraction options and the
+// page rotation for scan.LoadAll
+func (f *extractFlags) options() (extract.Options, int, error) {
+	opts := extract.Options{
+		Threshold:        uint8(f.threshold),
+		Transparent:      f.transparent,
+		StrokeWidth:      f.strokeWidth,
+		DropoutTolerance: f.dropoutTolerance,
+		Jobs:             f.jobs,
+	}
 
-				fmt.Printf("  [%d,%d] '%c' -> %s\n", row, col, char, filename)
-			}
-		}
+	inkMode, err := imaging.ParseInkMode(f.inkMode)
+	if err != nil {
+		return opts, 0, err
+	}
+	if inkMode != imaging.InkOriginal && !f.transparent {
+		return opts, 0, fmt.Errorf("-ink %s needs a transparent background", inkMode)
 	}
+	opts.Ink = inkMode
 
-	// Generate glyphs.json
-	glyphsJSON := GlyphsJSON{
-		Version: 1,
-		CellSize: CellSize{
-			Width:  config.CellWidthMM,
-			Height: config.CellHeightMM,
-		},
-		Glyphs: glyphsMap,
+	if f.inkColor != "" {
+		c, err := imaging.ParseColor(f.inkColor)
+		if err != nil || c == nil {
+			return opts, 0, fmt.Errorf("invalid -ink-color %q", f.inkColor)
+		}
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		opts.InkColor = &nc
 	}
 
-	jsonPath := filepath.Join(outputDir, "glyphs.json")
-	jsonData, err := json.MarshalIndent(glyphsJSON, "", "  ")
+	dropout, rotation, err := f.scanOptions()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating JSON: %v\n", err)
-		os.Exit(1)
+		return opts, 0, err
 	}
-
-	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
-		os.Exit(1)
+	opts.Dropout = dropout
+
+	// The bundled font may be missing when run from elsewhere; only a font
+	// asked for by name has to load
+	if f.reference != "" {
+		ref, err := reference.Load(f.reference)
+		switch {
+		case err == nil:
+			opts.Reference = ref
+		case f.isSet("reference"):
+			return opts, 0, fmt.Errorf("loading reference font: %w", err)
+		default:
+			fmt.Printf("Reference font %s not found, not checking for misplaced characters\n", f.reference)
+		}
 	}
 
-	fmt.Printf("\nDone! Extracted %d glyphs to %s\n", len(glyphsMap), outputDir)
-	fmt.Printf("JSON manifest: %s\n", jsonPath)
+	return opts, rotation, nil
 }
 
-// loadImage loads an image from file (supports PNG and JPEG)
-func loadImage(path string) (image.Image, error) {
-	file, err := os.Open(path)
+// scanOptions validates the flags of addScanFlags and returns the dropout
+// colour, nil for none, and the page rotation for scan.LoadAll
+func (f *extractFlags) scanOptions() (*color.NRGBA, int, error) {
+	var dropout *color.NRGBA
+	if f.dropout != "" {
+		c, err := imaging.ParseDropoutColor(f.dropout)
+		if err != nil {
+			return nil, 0, err
+		}
+		dropout = &c
+	}
+	rotation, err := scan.ParseOrientation(f.orientation)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer file.Close()
+	return dropout, rotation, nil
+}
 
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".png":
-		return png.Decode(file)
-	case ".jpg", ".jpeg":
-		return jpeg.Decode(file)
-	default:
-		// Try to decode as any format
-		img, _, err := image.Decode(file)
-		return img, err
-	}
+// isSet reports whether the flag was given on the command line
+func (f *extractFlags) isSet(name string) bool {
+	set := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-// savePNG saves an image as PNG
-func savePNG(img image.Image, path string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+// gridConfig returns the layout of sheet for a set of pages. The resolution
+// recorded in the scans is used unless -dpi was given.
+func (f *extractFlags) gridConfig(pages []scan.Page, sheet template.Config, log io.Writer) layout.GridConfig {
+	dpi := f.dpi
+	dpiSet := f.isSet("dpi")
+	for i, page := range pages {
+		// Cameras record a nominal 72 DPI that says nothing about the page
+		if page.DPI > 0 && page.DPI < scan.MinDPI {
+			fmt.Fprintf(log, "Ignoring %d DPI recorded in %s (camera default, pass -dpi)\n", page.DPI, page.Source)
+			pages[i].DPI = 0
+		}
+	}
+	if !dpiSet && len(pages) > 0 && pages[0].DPI > 0 {
+		dpi = pages[0].DPI
+		fmt.Fprintf(log, "Using %d DPI from %s\n", dpi, pages[0].Source)
+	}
+	for _, page := range pages {
+		if page.DPI > 0 && page.DPI != dpi {
+			fmt.Fprintf(log, "Warning: %s is %d DPI, extracting at %d DPI\n", page.Source, page.DPI, dpi)
+		}
 	}
-	defer file.Close()
 
-	return png.Encode(file, img)
+	return layout.GridConfig{
+		CellWidthMM:  sheet.CellWidthMM,
+		CellHeightMM: sheet.CellHeightMM,
+		Columns:      sheet.Columns,
+		Rows:         sheet.Rows,
+		DPI:          dpi,
+		MarginTopMM:  f.marginTop,
+		MarginLeftMM: f.marginLeft,
+	}
 }
 
 // renameGlyphs renames glyph files to ASCII-safe names and regenerates glyphs.json
@@ -259,7 +416,7 @@ func renameGlyphs(glyphsDir string) error {
 		charPart := strings.TrimSuffix(oldName, ".png")
 
 		// Handle special filenames that are already converted
-		char := filenameToChar(charPart)
+		char := charset.FilenameToChar(charPart)
 		normalized := norm.NFC.String(char)
 		oldFiles[normalized] = oldName
 
@@ -274,13 +431,14 @@ func renameGlyphs(glyphsDir string) error {
 
 	// Process each character in Charset
 	glyphsMap := make(map[string]string)
+	var ligatures []string
+	var forms []manifest.GlyphForm
 	renamed := 0
 	missing := 0
 
-	for _, r := range Charset {
-		char := string(r)
+	for _, char := range charset.Charset {
 		normalized := norm.NFC.String(char)
-		newFilename := CharToFilename(r) + ".png"
+		newFilename := charset.CharToFilename(char) + ".png"
 
 		oldFilename, exists := oldFiles[normalized]
 		if !exists {
@@ -290,13 +448,19 @@ func renameGlyphs(glyphsDir string) error {
 		}
 
 		if !exists {
-			fmt.Printf("  MISSING: '%s' (U+%04X)\n", char, r)
+			fmt.Printf("  MISSING: '%s' (%U)\n", char, []rune(char))
 			missing++
 			continue
 		}
 
 		// Add to glyphs map
 		glyphsMap[char] = newFilename
+		if charset.IsLigature(char) {
+			ligatures = append(ligatures, char)
+		}
+		if base, position := charset.SplitPosition(char); position != "" {
+			forms = append(forms, manifest.GlyphForm{Glyph: char, Base: base, Position: position})
+		}
 
 		// Rename if needed
 		if oldFilename != newFilename {
@@ -321,13 +485,15 @@ func renameGlyphs(glyphsDir string) error {
 	fmt.Printf("\nRenamed %d files, %d missing\n", renamed, missing)
 
 	// Generate glyphs.json
-	output := GlyphsJSON{
+	output := manifest.GlyphsJSON{
 		Version: 1,
-		CellSize: CellSize{
+		CellSize: manifest.CellSize{
 			Width:  22.5,
 			Height: 26.2,
 		},
-		Glyphs: glyphsMap,
+		Glyphs:    glyphsMap,
+		Ligatures: ligatures,
+		Forms:     forms,
 	}
 
 	jsonData, err := json.MarshalIndent(output, "", "  ")
@@ -351,101 +517,56 @@ func renameGlyphs(glyphsDir string) error {
 		validFiles[filename] = true
 	}
 
+	// Only files that hold a charset character are safe to remove; anything
+	// else may be a glyph this version cannot name and is kept
+	inCharset := make(map[string]bool)
+	for _, char := range charset.Charset {
+		inCharset[norm.NFC.String(char)] = true
+	}
+
 	cleaned := 0
 	for _, entry := range entries {
-		if !validFiles[entry.Name()] && strings.HasSuffix(entry.Name(), ".png") {
-			path := filepath.Join(glyphsDir, entry.Name())
-			fmt.Printf("  REMOVING unused: %s\n", entry.Name())
-			os.Remove(path)
-			cleaned++
+		if validFiles[entry.Name()] || !strings.HasSuffix(entry.Name(), ".png") {
+			continue
 		}
+		char := charset.FilenameToChar(strings.TrimSuffix(entry.Name(), ".png"))
+		if !inCharset[norm.NFC.String(char)] {
+			fmt.Printf("  KEEPING unknown: %s\n", entry.Name())
+			continue
+		}
+		path := filepath.Join(glyphsDir, entry.Name())
+		fmt.Printf("  REMOVING unused: %s\n", entry.Name())
+		os.Remove(path)
+		cleaned++
 	}
 	fmt.Printf("Cleaned up %d unused files\n", cleaned)
 
 	return nil
 }
 
-// filenameToChar converts a filename back to character
-func filenameToChar(name string) string {
-	switch name {
-	case "dot":
-		return "."
-	case "comma":
-		return ","
-	case "exclaim":
-		return "!"
-	case "question":
-		return "?"
-	case "colon":
-		return ":"
-	case "semicolon":
-		return ";"
-	case "hyphen":
-		return "-"
-	case "underscore":
-		return "_"
-	case "apostrophe":
-		return "'"
-	case "doublequote":
-		return "\""
-	case "slash":
-		return "/"
-	case "backslash":
-		return "\\"
-	case "at":
-		return "@"
-	case "hash":
-		return "#"
-	case "ampersand":
-		return "&"
-	case "plus":
-		return "+"
-	case "equals":
-		return "="
-	case "percent":
-		return "%"
-	case "asterisk":
-		return "*"
-	case "dollar":
-		return "$"
-	case "space":
-		return " "
-	case "lparen":
-		return "("
-	case "rparen":
-		return ")"
-	case "lbracket":
-		return "["
-	case "rbracket":
-		return "]"
-	case "lbrace":
-		return "{"
-	case "rbrace":
-		return "}"
-	case "less":
-		return "<"
-	case "greater":
-		return ">"
-	case "tilde":
-		return "~"
-	case "backtick":
-		return "`"
-	case "caret":
-		return "^"
-	case "pipe":
-		return "|"
-	default:
-		return name
-	}
-}
-
-// reprocessGlyphs applies MakeTransparent to all existing PNG files in a directory
-func reprocessGlyphs(dir string, threshold uint8) error {
+// reprocessGlyphs applies MakeTransparent to all existing PNG files in a directory,
+// optionally normalizing the pen width. Stroke statistics in glyphs.json next
+// to or above the directory are brought up to date.
+func reprocessGlyphs(dir string, threshold uint8, strokeWidth float64) error {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return fmt.Errorf("reading directory: %w", err)
 	}
 
+	// Metrics are keyed by character, so map filenames back through the manifest
+	var glyphsJSON *manifest.GlyphsJSON
+	manifestPath := ""
+	keys := make(map[string]string)
+	for _, candidate := range []string{filepath.Join(dir, "glyphs.json"), filepath.Join(dir, "..", "glyphs.json")} {
+		if m, err := manifest.Load(candidate); err == nil {
+			glyphsJSON, manifestPath = m, candidate
+			for key, filename := range m.Glyphs {
+				keys[filename] = key
+			}
+			break
+		}
+	}
+
 	processed := 0
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".png") {
@@ -453,22 +574,91 @@ func reprocessGlyphs(dir string, threshold uint8) error {
 		}
 
 		path := filepath.Join(dir, entry.Name())
-		img, err := loadImage(path)
+		img, err := imaging.LoadImage(path)
 		if err != nil {
 			fmt.Printf("  SKIP %s: %v\n", entry.Name(), err)
 			continue
 		}
 
-		result := MakeTransparent(img, threshold)
-		if err := savePNG(result, path); err != nil {
+		result := imaging.MakeTransparent(img, threshold)
+		grow := 0
+		if strokeWidth > 0 {
+			result, grow = imaging.NormalizeStroke(result, strokeWidth)
+		}
+		if err := imaging.SavePNG(result, path); err != nil {
 			fmt.Printf("  ERROR %s: %v\n", entry.Name(), err)
 			continue
 		}
 
+		if key, ok := keys[entry.Name()]; ok && glyphsJSON.Metrics != nil {
+			metrics := glyphsJSON.Metrics[key]
+			metrics.Width, metrics.Height = result.Bounds().Dx(), result.Bounds().Dy()
+			metrics.CellX -= grow
+			metrics.CellY -= grow
+			metrics.Baseline += grow
+			for _, p := range []*manifest.ConnectPoint{metrics.Entry, metrics.Exit} {
+				if p != nil {
+					p.X += grow
+					p.Y += grow
+				}
+			}
+			metrics.StrokeWidth = imaging.StrokeWidth(imaging.NewMask(result, imaging.GlyphInkThreshold))
+			glyphsJSON.Metrics[key] = metrics
+		}
+
 		processed++
 		fmt.Printf("  OK %s\n", entry.Name())
 	}
 
 	fmt.Printf("\nReprocessed %d glyphs with threshold %d\n", processed, threshold)
+
+	if glyphsJSON != nil && glyphsJSON.Metrics != nil {
+		glyphsJSON.Stroke = manifest.NewStrokeStats(glyphsJSON.Metrics)
+		if err := manifest.Write(glyphsJSON, manifestPath); err != nil {
+			return fmt.Errorf("writing JSON: %w", err)
+		}
+		printStrokeStats(glyphsJSON.Stroke)
+		fmt.Printf("Updated %s\n", manifestPath)
+	}
 	return nil
 }
+
+// printCellWarnings lists the cells the extraction checks flagged
+func printCellWarnings(cells *manifest.CellsJSON) {
+	if cells == nil {
+		return
+	}
+	header := false
+	for _, c := range cells.Cells {
+		for _, w := range c.Warnings {
+			if !header {
+				fmt.Println("\nCheck these cells:")
+				header = true
+			}
+			fmt.Printf("  page %d [%d,%d] '%s': %s\n", c.Page, c.Row, c.Column, c.Char, w)
+		}
+	}
+}
+
+// printShifts reports runs of cells that hold the wrong characters
+func printShifts(shifts []manifest.Shift) {
+	if len(shifts) == 0 {
+		return
+	}
+	fmt.Println("\nMisplaced characters:")
+	for _, s := range shifts {
+		fmt.Printf("  %s\n", s.Suggestion)
+	}
+}
+
+// printStrokeStats reports the pen width of a set and the glyphs that stray from it
+func printStrokeStats(stats *manifest.StrokeStats) {
+	if stats == nil {
+		return
+	}
+	fmt.Printf("\nStroke width: median %.1f px (min %.1f, max %.1f, stddev %.2f)\n",
+		stats.Median, stats.Min, stats.Max, stats.StdDev)
+	if len(stats.Outliers) > 0 {
+		fmt.Printf("  Check these glyphs, their pen width differs: %s\n", strings.Join(stats.Outliers, " "))
+	}
+}
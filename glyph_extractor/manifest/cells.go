@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+)
+
+// CellsJSON records how every cell of an extraction was cut, for auditing
+// and post-processing; written as cells.json next to glyphs.json
+type CellsJSON struct {
+	Version int    `json:"version"`
+	Cells   []Cell `json:"cells"` // In template order
+}
+
+// Cell describes one extracted template cell. Rectangles are in pixels of the
+// upright page.
+type Cell struct {
+	Page       int      `json:"page"`               // Page number, from 1
+	Source     string   `json:"source,omitempty"`   // Page name in the log, e.g. "scan.pdf page 2"
+	Scan       string   `json:"scan,omitempty"`     // Scan file the page was loaded from
+	ScanPage   int      `json:"scanPage,omitempty"` // Page within the scan file, from 1
+	Row        int      `json:"row"`
+	Column     int      `json:"column"`
+	Char       string   `json:"char"`
+	File       string   `json:"file,omitempty"`       // Glyph PNG written for the cell
+	Rect       Rect     `json:"rect"`                 // The cell
+	Trim       Rect     `json:"trim"`                 // The glyph image cut from the cell
+	InkRatio   float64  `json:"inkRatio"`             // Share of the cell's pixels that are ink
+	Components int      `json:"components"`           // Separate 8-connected blobs of ink
+	Threshold  uint8    `json:"threshold"`            // White threshold the cell was cut with
+	Similarity float64  `json:"similarity,omitempty"` // To the character in the reference font, 0 if not compared
+	Empty      bool     `json:"empty,omitempty"`      // Blank; no glyph was written
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// Rect is a pixel rectangle
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// NewRect converts an image rectangle
+func NewRect(r image.Rectangle) Rect {
+	return Rect{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// Rectangle converts back to an image rectangle
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// LoadCells reads a cells.json file
+func LoadCells(path string) (*CellsJSON, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cells CellsJSON
+	if err := json.Unmarshal(data, &cells); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cells, nil
+}
+
+// WriteCells writes a cells.json file
+func WriteCells(cells *CellsJSON, path string) error {
+	data, err := json.MarshalIndent(cells, "", "  ")
+	if err != nil {
+		return fmt.Errorf("creating JSON: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,43 @@
+package manifest
+
+// KerningPair adjusts the advance between two glyphs, in scan pixels.
+// Negative amounts move the right glyph closer.
+type KerningPair struct {
+	Left   string `json:"left"`
+	Right  string `json:"right"`
+	Amount int    `json:"amount"`
+}
+
+// KerningTable indexes kerning pairs by their glyph keys
+type KerningTable map[[2]string]int
+
+// NewKerningTable indexes pairs; a later pair for the same glyphs wins
+func NewKerningTable(pairs []KerningPair) KerningTable {
+	table := make(KerningTable, len(pairs))
+	for _, p := range pairs {
+		table[[2]string{p.Left, p.Right}] = p.Amount
+	}
+	return table
+}
+
+// KerningPairs returns the kerning the set should be set with: the automatic
+// table, with every pair the writer actually wrote taking precedence
+func (s *GlyphSet) KerningPairs() []KerningPair {
+	measured := NewKerningTable(s.Manifest.MeasuredKerning)
+
+	var pairs []KerningPair
+	for _, p := range s.Manifest.Kerning {
+		if _, ok := measured[[2]string{p.Left, p.Right}]; !ok {
+			pairs = append(pairs, p)
+		}
+	}
+	return append(pairs, s.Manifest.MeasuredKerning...)
+}
+
+// Kerning returns the kerning between two glyphs in scan pixels
+func (s *GlyphSet) Kerning(left, right string) int {
+	if s.kerning == nil {
+		s.kerning = NewKerningTable(s.KerningPairs())
+	}
+	return s.kerning[[2]string{left, right}]
+}
@@ -0,0 +1,322 @@
+// Package manifest reads and writes glyphs.json, the description of an
+// extracted glyph set, and loads glyph sets back from disk.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/layout"
+)
+
+// GlyphsJSON represents the output JSON structure
+type GlyphsJSON struct {
+	Version  int                     `json:"version"`
+	CellSize CellSize                `json:"cellSize"`
+	DPI      int                     `json:"dpi,omitempty"`
+	XHeight  int                     `json:"xHeight,omitempty"`
+	Glyphs   map[string]string       `json:"glyphs"`
+	Metrics  map[string]GlyphMetrics `json:"metrics,omitempty"`
+	Kerning  []KerningPair           `json:"kerning,omitempty"`
+
+	// Spacing measured from the kerning pairs sheet; overrides Kerning
+	MeasuredKerning []KerningPair `json:"measuredKerning,omitempty"`
+
+	// Multi-character glyph keys a renderer substitutes for their letters
+	Ligatures []string `json:"ligatures,omitempty"`
+
+	// Positional forms of letters, chosen by where the letter sits in a word
+	Forms []GlyphForm `json:"forms,omitempty"`
+
+	// Pen width statistics over all glyphs
+	Stroke *StrokeStats `json:"stroke,omitempty"`
+
+	// How the glyph PNGs are coloured and the dominant ink colour of each page
+	Ink       imaging.InkMode `json:"ink,omitempty"`
+	InkColors []string        `json:"inkColors,omitempty"`
+
+	// Cells left blank, which have no glyph, and the problems the extraction
+	// checks found with glyphs, by key
+	Empty    []string            `json:"empty,omitempty"`
+	Warnings map[string][]string `json:"warnings,omitempty"`
+
+	// Glyphs that look little like their character in the reference font or
+	// sit in a run of shifted cells, and the runs with a suggested fix
+	LowConfidence []string `json:"lowConfidence,omitempty"`
+	Shifts        []Shift  `json:"shifts,omitempty"`
+}
+
+// GlyphForm tags a glyph as the positional form of a base character
+type GlyphForm struct {
+	Glyph    string `json:"glyph"`    // Glyph key, e.g. "a.fina"
+	Base     string `json:"base"`     // Character it replaces, e.g. "a"
+	Position string `json:"position"` // init, medi, fina or isol
+}
+
+// Shift is a run of template cells that each hold the character Offset
+// places later in the charset than the one they were meant for
+type Shift struct {
+	From       string `json:"from"` // Character of the first cell of the run
+	To         string `json:"to"`   // Character of the last cell
+	Offset     int    `json:"offset"`
+	Suggestion string `json:"suggestion"`
+}
+
+type CellSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// GlyphMetrics describes where a glyph image sat inside its template cell.
+// All values are in pixels at the scan DPI.
+type GlyphMetrics struct {
+	Width    int `json:"width"`    // Glyph image width
+	Height   int `json:"height"`   // Glyph image height
+	CellX    int `json:"cellX"`    // Left edge of the glyph image within the cell
+	CellY    int `json:"cellY"`    // Top edge of the glyph image within the cell
+	Baseline int `json:"baseline"` // Baseline y measured from the top of the glyph image
+
+	// Cursive connection points and the pen width for connecting strokes
+	Entry       *ConnectPoint `json:"entry,omitempty"`
+	Exit        *ConnectPoint `json:"exit,omitempty"`
+	StrokeWidth float64       `json:"strokeWidth,omitempty"`
+}
+
+// ConnectPoint is where a connecting stroke enters or leaves a glyph, in
+// glyph image pixels
+type ConnectPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Grid returns the grid geometry the set was extracted with.
+// Manifests written before the DPI was recorded fall back to the default DPI.
+func (m *GlyphsJSON) Grid() layout.GridConfig {
+	config := layout.DefaultConfig()
+	config.CellWidthMM = m.CellSize.Width
+	config.CellHeightMM = m.CellSize.Height
+	if m.DPI > 0 {
+		config.DPI = m.DPI
+	}
+	return config
+}
+
+// Glyph is a single loaded glyph image together with its metrics
+type Glyph struct {
+	Key     string
+	File    string
+	Image   image.Image
+	Metrics GlyphMetrics
+}
+
+// GlyphSet is an extracted glyph set loaded back from disk
+type GlyphSet struct {
+	Dir      string // Directory holding the glyph PNGs
+	Manifest GlyphsJSON
+	Glyphs   map[string]*Glyph
+	Cells    *CellsJSON // How the cells were extracted, nil if not recorded
+
+	kerning KerningTable
+	forms   map[[2]string]*Glyph
+}
+
+// Load reads a glyphs.json file
+func Load(path string) (*GlyphsJSON, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest GlyphsJSON
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Write writes a glyphs.json file
+func Write(manifest *GlyphsJSON, path string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("creating JSON: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Find locates glyphs.json for a glyph set. The path may point at the
+// manifest itself or at a directory that contains it.
+func Find(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	manifestPath := filepath.Join(path, "glyphs.json")
+	if _, err := os.Stat(manifestPath); err != nil {
+		return "", fmt.Errorf("no glyphs.json in %s", path)
+	}
+	return manifestPath, nil
+}
+
+// LoadGlyphSet loads glyphs.json and every PNG it references.
+// Both layouts produced by this tool are supported: PNGs next to the manifest
+// (rename, assets folder) and PNGs in a glyphs/ subdirectory (extraction output).
+func LoadGlyphSet(path string) (*GlyphSet, error) {
+	manifestPath, err := Find(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := Load(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := filepath.Dir(manifestPath)
+	glyphDir := baseDir
+	if info, err := os.Stat(filepath.Join(baseDir, "glyphs")); err == nil && info.IsDir() {
+		glyphDir = filepath.Join(baseDir, "glyphs")
+	}
+
+	set := &GlyphSet{
+		Dir:      glyphDir,
+		Manifest: *manifest,
+		Glyphs:   make(map[string]*Glyph),
+	}
+	// Older extractions and renamed sets have no cell record
+	cells, err := LoadCells(filepath.Join(baseDir, "cells.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	set.Cells = cells
+
+	for key, filename := range manifest.Glyphs {
+		img, err := imaging.LoadImage(filepath.Join(glyphDir, filename))
+		if err != nil {
+			return nil, fmt.Errorf("loading glyph '%s': %w", key, err)
+		}
+
+		metrics, ok := manifest.Metrics[key]
+		if !ok {
+			// Older manifests carry no metrics — assume the glyph sits on the baseline
+			metrics = GlyphMetrics{
+				Width:    img.Bounds().Dx(),
+				Height:   img.Bounds().Dy(),
+				Baseline: img.Bounds().Dy(),
+			}
+		}
+
+		set.Glyphs[key] = &Glyph{
+			Key:     key,
+			File:    filename,
+			Image:   img,
+			Metrics: metrics,
+		}
+	}
+
+	return set, nil
+}
+
+// Keys returns glyph keys in charset order, followed by any extra glyphs sorted
+func (s *GlyphSet) Keys() []string {
+	keys := make([]string, 0, len(s.Glyphs))
+	seen := make(map[string]bool)
+	for _, key := range charset.Charset {
+		if _, ok := s.Glyphs[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	var extra []string
+	for key := range s.Glyphs {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+
+	return append(keys, extra...)
+}
+
+// Ligatures returns the ligatures available in the set, longest first so a
+// greedy match prefers "sch" over "ch"
+func (s *GlyphSet) Ligatures() []string {
+	var ligatures []string
+	for _, key := range s.Manifest.Ligatures {
+		if _, ok := s.Glyphs[key]; ok && charset.IsLigature(key) {
+			ligatures = append(ligatures, key)
+		}
+	}
+	sort.SliceStable(ligatures, func(i, j int) bool {
+		return utf8.RuneCountInString(ligatures[i]) > utf8.RuneCountInString(ligatures[j])
+	})
+	return ligatures
+}
+
+// Form returns the positional form of a glyph, if the set has one
+func (s *GlyphSet) Form(key, position string) (*Glyph, bool) {
+	if s.forms == nil {
+		s.forms = make(map[[2]string]*Glyph, len(s.Manifest.Forms))
+		for _, f := range s.Manifest.Forms {
+			if glyph, ok := s.Glyphs[f.Glyph]; ok {
+				s.forms[[2]string{f.Base, f.Position}] = glyph
+			}
+		}
+	}
+	glyph, ok := s.forms[[2]string{key, position}]
+	return glyph, ok
+}
+
+// LineBox returns the height of one line of text and the baseline position
+// within it, in scan pixels. The line box is the template cell; without a
+// recorded DPI the cell size in pixels is a guess, so it is derived from the
+// glyphs themselves instead.
+func (s *GlyphSet) LineBox() (height, base int) {
+	if s.Manifest.DPI > 0 {
+		grid := s.Manifest.Grid()
+		return grid.CellHeightPx(), grid.BaselinePx()
+	}
+
+	ascent, descent := 0, 0
+	for _, glyph := range s.Glyphs {
+		ascent = max(ascent, glyph.Metrics.Baseline)
+		descent = max(descent, glyph.Metrics.Height-glyph.Metrics.Baseline)
+	}
+	return ascent + descent, ascent
+}
+
+// AverageWidth returns the average width of the lowercase letters in the set,
+// or of all glyphs when there are none
+func (s *GlyphSet) AverageWidth() float64 {
+	total, count := 0, 0
+	for key, glyph := range s.Glyphs {
+		r, size := utf8.DecodeRuneInString(key)
+		if size == len(key) && unicode.IsLower(r) {
+			total += glyph.Metrics.Width
+			count++
+		}
+	}
+
+	if count == 0 {
+		for _, glyph := range s.Glyphs {
+			total += glyph.Metrics.Width
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}
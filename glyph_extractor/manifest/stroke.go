@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"math"
+	"sort"
+)
+
+// strokeOutlierRatio is how far a glyph's stroke width may stray from the set
+// median, as a fraction of it, before the glyph is reported
+const strokeOutlierRatio = 0.35
+
+// StrokeStats summarizes the stroke widths of a glyph set so glyphs written
+// with a different pen stand out
+type StrokeStats struct {
+	Median   float64  `json:"median"`
+	Mean     float64  `json:"mean"`
+	StdDev   float64  `json:"stdDev"`
+	Min      float64  `json:"min"`
+	Max      float64  `json:"max"`
+	Outliers []string `json:"outliers,omitempty"` // Glyphs far from the median, sorted
+}
+
+// NewStrokeStats computes stroke statistics from per-glyph widths. Glyphs
+// without a measured width are ignored.
+func NewStrokeStats(metrics map[string]GlyphMetrics) *StrokeStats {
+	var widths []float64
+	for _, m := range metrics {
+		if m.StrokeWidth > 0 {
+			widths = append(widths, m.StrokeWidth)
+		}
+	}
+	if len(widths) == 0 {
+		return nil
+	}
+	sort.Float64s(widths)
+
+	stats := &StrokeStats{
+		Median: widths[len(widths)/2],
+		Min:    widths[0],
+		Max:    widths[len(widths)-1],
+	}
+	for _, w := range widths {
+		stats.Mean += w
+	}
+	stats.Mean /= float64(len(widths))
+	for _, w := range widths {
+		stats.StdDev += (w - stats.Mean) * (w - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(stats.StdDev / float64(len(widths)))
+
+	for key, m := range metrics {
+		if m.StrokeWidth > 0 && math.Abs(m.StrokeWidth-stats.Median) > strokeOutlierRatio*stats.Median {
+			stats.Outliers = append(stats.Outliers, key)
+		}
+	}
+	sort.Strings(stats.Outliers)
+	return stats
+}
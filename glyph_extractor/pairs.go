@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"sort"
+	"strings"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+	"glyph_extractor/scan"
+	"glyph_extractor/template"
+)
+
+const (
+	// minComponentRatio is the smallest ink blob kept when measuring a pair, as
+	// a fraction of the cell area — anything smaller is scanner noise
+	minComponentRatio = 0.0003
+
+	// markRatio is the size below which a blob may be a mark of a letter
+	// rather than a letter, as a fraction of the largest blob in the cell
+	markRatio = 0.2
+)
+
+// splitPairCell locates the two hand-written glyphs in a pairs sheet cell and
+// returns their ink bounding boxes. Letters are told apart by their connected
+// ink, so a letter tucked under the overhang of the one before it is found
+// and the boxes may overlap: the gap between them is negative then. Small
+// blobs with a letter below them, diacritics and dots, join that letter.
+func splitPairCell(cell image.Image, threshold uint8) (left, right image.Rectangle, err error) {
+	// Same ink criterion as TrimWhitespace
+	mask := imaging.NewMask(cell, uint8(int(threshold)*3/4))
+	minArea := int(minComponentRatio * float64(cell.Bounds().Dx()*cell.Bounds().Dy()))
+	labels, components := mask.Label()
+
+	largest := 0
+	for _, c := range components {
+		largest = max(largest, c.Area)
+	}
+	if largest < minArea {
+		return image.Rectangle{}, image.Rectangle{}, fmt.Errorf("empty cell")
+	}
+
+	// Every blob starts as a group of its own; marks are moved to the group of
+	// the first ink found below them
+	group := make([]int, len(components))
+	for i := range group {
+		group[i] = i
+	}
+	w := mask.Bounds.Dx()
+	for i, c := range components {
+		if c.Area < minArea || c.Area >= int(markRatio*float64(largest)) {
+			continue
+		}
+		below := -1
+		for y := c.Bounds.Max.Y; y < mask.Bounds.Max.Y && below < 0; y++ {
+			for x := c.Bounds.Min.X; x < c.Bounds.Max.X; x++ {
+				j := labels[(y-mask.Bounds.Min.Y)*w+x-mask.Bounds.Min.X]
+				if j >= 0 && j != i && components[j].Area >= minArea {
+					below = j
+					break
+				}
+			}
+		}
+		if below >= 0 && components[below].Area >= int(markRatio*float64(largest)) {
+			group[i] = below
+		}
+	}
+
+	bounds := make(map[int]image.Rectangle)
+	for i, c := range components {
+		if c.Area >= minArea {
+			bounds[group[i]] = bounds[group[i]].Union(c.Bounds)
+		}
+	}
+	var glyphs []image.Rectangle
+	for _, b := range bounds {
+		glyphs = append(glyphs, b)
+	}
+	if len(glyphs) == 1 {
+		return image.Rectangle{}, image.Rectangle{}, fmt.Errorf("letters are joined, cannot separate them")
+	}
+
+	// A letter written in several strokes: keep joining the neighbours that
+	// overlap most or are closest until two remain
+	sort.Slice(glyphs, func(i, j int) bool {
+		if glyphs[i].Min.X != glyphs[j].Min.X {
+			return glyphs[i].Min.X < glyphs[j].Min.X
+		}
+		return glyphs[i].Min.Y < glyphs[j].Min.Y
+	})
+	for len(glyphs) > 2 {
+		best := 0
+		for i := 1; i < len(glyphs)-1; i++ {
+			if glyphs[i+1].Min.X-glyphs[i].Max.X < glyphs[best+1].Min.X-glyphs[best].Max.X {
+				best = i
+			}
+		}
+		glyphs[best] = glyphs[best].Union(glyphs[best+1])
+		glyphs = append(glyphs[:best+1], glyphs[best+2:]...)
+	}
+
+	return glyphs[0], glyphs[1], nil
+}
+
+// measurePair turns the measured ink gap of a hand-written pair into a kerning
+// amount: the difference to the gap the renderer leaves without kerning
+func measurePair(set *manifest.GlyphSet, left, right string, inkGap int) (manifest.KerningPair, error) {
+	l, ok := set.Glyphs[left]
+	if !ok {
+		return manifest.KerningPair{}, fmt.Errorf("no glyph for '%s'", left)
+	}
+	r, ok := set.Glyphs[right]
+	if !ok {
+		return manifest.KerningPair{}, fmt.Errorf("no glyph for '%s'", right)
+	}
+
+	lb := imaging.NewMask(l.Image, imaging.GlyphInkThreshold).InkBounds()
+	rb := imaging.NewMask(r.Image, imaging.GlyphInkThreshold).InkBounds()
+	if lb.Empty() || rb.Empty() {
+		return manifest.KerningPair{}, fmt.Errorf("glyph has no ink")
+	}
+
+	defaultGap := (l.Image.Bounds().Max.X - lb.Max.X) + (rb.Min.X - r.Image.Bounds().Min.X)
+	return manifest.KerningPair{Left: left, Right: right, Amount: inkGap - defaultGap}, nil
+}
+
+// runPairs implements the pairs subcommand
+func runPairs(args []string) error {
+	var inputFiles string
+
+	fs := flag.NewFlagSet("pairs", flag.ExitOnError)
+	fs.StringVar(&inputFiles, "input", "", "Scanned pairs sheets, images or PDFs (comma-separated)")
+	scanFlags := addScanFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor pairs --input sheet1.png[,sheet2.png] [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if inputFiles == "" || fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	dropout, rotation, err := scanFlags.scanOptions()
+	if err != nil {
+		return err
+	}
+
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	set, err := manifest.LoadGlyphSet(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	measured := manifest.NewKerningTable(set.Manifest.MeasuredKerning)
+	pairIndex := 0
+	found := 0
+
+	files := strings.Split(inputFiles, ",")
+	for i := range files {
+		files[i] = strings.TrimSpace(files[i])
+	}
+	pages, err := scan.LoadAll(files, rotation)
+	if err != nil {
+		return err
+	}
+	config := scanFlags.gridConfig(pages, template.DefaultPairsConfig(), os.Stdout)
+
+	for pageIndex, page := range pages {
+		fmt.Printf("Processing pairs page %d: %s\n", pageIndex+1, page.Source)
+		img := page.Image
+		if dropout != nil {
+			img = imaging.RemoveDropout(img, *dropout, scanFlags.dropoutTolerance)
+		}
+
+		for row := 0; row < config.Rows; row++ {
+			for col := 0; col < config.Columns; col++ {
+				if pairIndex >= len(charset.KerningPairs) {
+					break
+				}
+				pair := []rune(charset.KerningPairs[pairIndex])
+				pairIndex++
+				left, right := string(pair[0]), string(pair[1])
+
+				cell := config.ExtractCell(img, row, col)
+				lb, rb, err := splitPairCell(cell, uint8(scanFlags.threshold))
+				if err != nil {
+					fmt.Printf("  [%d,%d] '%s%s' SKIP: %v\n", row, col, left, right, err)
+					continue
+				}
+
+				kp, err := measurePair(set, left, right, rb.Min.X-lb.Max.X)
+				if err != nil {
+					fmt.Printf("  [%d,%d] '%s%s' SKIP: %v\n", row, col, left, right, err)
+					continue
+				}
+
+				measured[[2]string{left, right}] = kp.Amount
+				found++
+				fmt.Printf("  [%d,%d] '%s%s' -> %+d px\n", row, col, left, right, kp.Amount)
+			}
+		}
+	}
+
+	// Keep pairs measured earlier that this run didn't cover, in sheet order
+	set.Manifest.MeasuredKerning = nil
+	for _, p := range charset.KerningPairs {
+		pair := []rune(p)
+		key := [2]string{string(pair[0]), string(pair[1])}
+		if amount, ok := measured[key]; ok {
+			set.Manifest.MeasuredKerning = append(set.Manifest.MeasuredKerning,
+				manifest.KerningPair{Left: key[0], Right: key[1], Amount: amount})
+			delete(measured, key)
+		}
+	}
+	var rest []manifest.KerningPair
+	for key, amount := range measured {
+		rest = append(rest, manifest.KerningPair{Left: key[0], Right: key[1], Amount: amount})
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i].Left != rest[j].Left {
+			return rest[i].Left < rest[j].Left
+		}
+		return rest[i].Right < rest[j].Right
+	})
+	set.Manifest.MeasuredKerning = append(set.Manifest.MeasuredKerning, rest...)
+
+	if err := manifest.Write(&set.Manifest, manifestPath); err != nil {
+		return fmt.Errorf("writing JSON: %w", err)
+	}
+
+	fmt.Printf("\nMeasured %d of %d pairs, written to %s\n", found, len(charset.KerningPairs), manifestPath)
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+	"glyph_extractor/manifest"
+)
+
+// Contact sheet colours
+var (
+	previewGridColor     = color.NRGBA{220, 220, 220, 255}
+	previewBoxColor      = color.NRGBA{120, 170, 255, 255} // Glyph image bounds
+	previewBaselineColor = color.NRGBA{255, 150, 150, 255}
+	previewLabelColor    = color.NRGBA{90, 90, 90, 255}
+	previewWarningColor  = color.NRGBA{220, 30, 30, 255}
+)
+
+// PreviewOptions controls the layout of a contact sheet
+type PreviewOptions struct {
+	Columns  int       // Tiles per row
+	TileSize int       // Width and height of the glyph area of a tile in pixels
+	Face     font.Face // Font of the labels
+}
+
+// previewTile is one entry of a contact sheet
+type previewTile struct {
+	key      string
+	glyph    *manifest.Glyph // nil when the set has no glyph for the key
+	empty    bool            // The writer left the cell blank
+	warnings []string
+}
+
+// previewTiles lists the charset in order, entries without a glyph included,
+// followed by any extra glyphs of the set
+func previewTiles(set *manifest.GlyphSet) []previewTile {
+	empty := make(map[string]bool)
+	for _, key := range set.Manifest.Empty {
+		empty[key] = true
+	}
+	outliers := make(map[string]bool)
+	if set.Manifest.Stroke != nil {
+		for _, key := range set.Manifest.Stroke.Outliers {
+			outliers[key] = true
+		}
+	}
+
+	tile := func(key string) previewTile {
+		t := previewTile{key: key, glyph: set.Glyphs[key], empty: empty[key]}
+		t.warnings = append(t.warnings, set.Manifest.Warnings[key]...)
+		if outliers[key] {
+			t.warnings = append(t.warnings, "pen width differs from the set")
+		}
+		return t
+	}
+
+	var tiles []previewTile
+	inCharset := make(map[string]bool)
+	for _, key := range charset.Charset {
+		tiles = append(tiles, tile(key))
+		inCharset[key] = true
+	}
+	for _, key := range set.Keys() {
+		if !inCharset[key] {
+			tiles = append(tiles, tile(key))
+		}
+	}
+	return tiles
+}
+
+// proofImage returns the glyph image to show on white paper: white mask
+// glyphs are drawn black, anything else as extracted
+func proofImage(set *manifest.GlyphSet, glyph *manifest.Glyph) image.Image {
+	if set.Manifest.Ink == imaging.InkMask {
+		return imaging.ApplyInk(glyph.Image, imaging.InkRecolor, color.NRGBA{0, 0, 0, 255})
+	}
+	return glyph.Image
+}
+
+// Preview draws the contact sheet of a glyph set: every glyph at one common
+// scale standing on its baseline, with its label, the bounds of its image and
+// a red frame if it drew warnings
+func Preview(set *manifest.GlyphSet, opts PreviewOptions) *image.RGBA {
+	tiles := previewTiles(set)
+	const padding = 8
+	ascent := opts.Face.Metrics().Ascent.Ceil()
+	labelHeight := opts.Face.Metrics().Height.Ceil() + 6
+	tileW, tileH := opts.TileSize, opts.TileSize+labelHeight
+	rows := (len(tiles) + opts.Columns - 1) / opts.Columns
+
+	// Common scale: the tallest stretch above and below the baseline and the
+	// widest glyph all fit a tile
+	lineHeight, base := set.LineBox()
+	top, bottom, width := 0, lineHeight, 1
+	for _, glyph := range set.Glyphs {
+		glyphTop := base - glyph.Metrics.Baseline
+		top = min(top, glyphTop)
+		bottom = max(bottom, glyphTop+glyph.Image.Bounds().Dy())
+		width = max(width, glyph.Image.Bounds().Dx())
+	}
+	scale := float64(opts.TileSize-2*padding) / float64(max(bottom-top, width, 1))
+	baselineY := padding + int(float64(base-top)*scale)
+
+	sheet := image.NewRGBA(image.Rect(0, 0, opts.Columns*tileW, rows*tileH))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for i, t := range tiles {
+		cell := image.Rect(0, 0, tileW, tileH).Add(image.Pt(i%opts.Columns*tileW, i/opts.Columns*tileH))
+		area := image.Rect(cell.Min.X, cell.Min.Y, cell.Max.X, cell.Min.Y+opts.TileSize)
+		strokeRect(sheet, cell, 1, previewGridColor)
+
+		// Baseline across the tile
+		y := area.Min.Y + baselineY
+		draw.Draw(sheet, image.Rect(area.Min.X+1, y, area.Max.X-1, y+1), image.NewUniform(previewBaselineColor), image.Point{}, draw.Over)
+
+		if t.glyph != nil {
+			img := proofImage(set, t.glyph)
+			w := max(1, int(float64(img.Bounds().Dx())*scale))
+			h := max(1, int(float64(img.Bounds().Dy())*scale))
+			x0 := area.Min.X + (opts.TileSize-w)/2
+			y0 := y - int(float64(t.glyph.Metrics.Baseline)*scale)
+			dst := image.Rect(x0, y0, x0+w, y0+h)
+			draw.CatmullRom.Scale(sheet, dst, img, img.Bounds(), draw.Over, nil)
+			strokeRect(sheet, dst.Inset(-1), 1, previewBoxColor)
+		} else {
+			note := "missing"
+			if t.empty {
+				note = "empty"
+			}
+			drawText(sheet, opts.Face, area.Min.X+(opts.TileSize-textWidth(opts.Face, note))/2,
+				area.Min.Y+opts.TileSize/2, note, previewGridColor)
+		}
+
+		labelColor := previewLabelColor
+		if len(t.warnings) > 0 || t.glyph == nil {
+			labelColor = previewWarningColor
+			strokeRect(sheet, cell.Inset(1), 2, previewWarningColor)
+		}
+		label := t.key
+		if len(t.warnings) > 0 {
+			label = fmt.Sprintf("%s (!%d)", t.key, len(t.warnings))
+		}
+		drawText(sheet, opts.Face, cell.Min.X+(tileW-textWidth(opts.Face, label))/2,
+			area.Max.Y+3+ascent, label, labelColor)
+	}
+	return sheet
+}
+
+// writePreviewPDF writes the contact sheet as a one-page PDF, A4 wide
+func writePreviewPDF(sheet image.Image, path string) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sheet); err != nil {
+		return err
+	}
+
+	const widthMM = 210.0
+	b := sheet.Bounds()
+	heightMM := widthMM * float64(b.Dy()) / float64(b.Dx())
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: widthMM, Ht: heightMM},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	pdf.RegisterImageOptionsReader("sheet", gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+	pdf.ImageOptions("sheet", 0, 0, widthMM, heightMM, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	return pdf.OutputFileAndClose(path)
+}
+
+// runPreview implements the preview subcommand
+func runPreview(args []string) error {
+	var output, pdfPath, fontPath string
+	var opts PreviewOptions
+
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	fs.StringVar(&output, "output", "", "Output PNG file (default preview.png next to glyphs.json)")
+	fs.StringVar(&pdfPath, "pdf", "", "Also write the contact sheet as this PDF file")
+	fs.IntVar(&opts.Columns, "columns", 10, "Glyphs per row")
+	fs.IntVar(&opts.TileSize, "tile", 120, "Size of a glyph tile in pixels")
+	fs.StringVar(&fontPath, "font", defaultFontPath, "TrueType font for the labels")
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor preview [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	if opts.Columns < 1 || opts.TileSize < 32 {
+		return fmt.Errorf("need at least 1 column and a tile of 32 pixels")
+	}
+
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	set, err := manifest.LoadGlyphSet(manifestPath)
+	if err != nil {
+		return err
+	}
+	opts.Face, err = loadFace(fontPath, max(11, float64(opts.TileSize)/9))
+	if err != nil {
+		return fmt.Errorf("loading label font: %w", err)
+	}
+
+	if output == "" {
+		output = filepath.Join(filepath.Dir(manifestPath), "preview.png")
+	}
+	sheet := Preview(set, opts)
+	if err := imaging.SavePNG(sheet, output); err != nil {
+		return err
+	}
+	fmt.Printf("Contact sheet of %d glyphs: %s\n", len(set.Glyphs), output)
+
+	if pdfPath != "" {
+		if err := writePreviewPDF(sheet, pdfPath); err != nil {
+			return fmt.Errorf("writing PDF: %w", err)
+		}
+		fmt.Printf("PDF: %s\n", pdfPath)
+	}
+	return nil
+}
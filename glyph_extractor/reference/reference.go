@@ -0,0 +1,147 @@
+// Package reference compares extracted glyphs with the same characters set in
+// a reference font, to catch cells that hold another character than the
+// template asked for.
+package reference
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/imaging"
+)
+
+const (
+	// shapeSize is the side of the square grid shapes are compared on
+	shapeSize = 32
+
+	// maxDistance caps the distance field in grid pixels, so that blank
+	// space far from any stroke does not outweigh the strokes themselves
+	maxDistance = 6
+
+	// renderSize is the pixel size reference characters are drawn at
+	renderSize = 128
+)
+
+// Font is a reference font characters are drawn with
+type Font struct {
+	font *sfnt.Font
+	face font.Face
+}
+
+// Load loads a TrueType or OpenType reference font
+func Load(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: renderSize, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, err
+	}
+	return &Font{font: f, face: face}, nil
+}
+
+// Shape returns the shape of a glyph key set in the font: the characters of
+// a ligature side by side, the base letter of a positional form. ok is false
+// if the font lacks one of the characters.
+func (f *Font) Shape(key string) (shape *Shape, ok bool) {
+	text, _ := charset.SplitPosition(key)
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if i, err := f.font.GlyphIndex(&buf, r); err != nil || i == 0 {
+			return nil, false
+		}
+	}
+
+	bounds, _ := font.BoundString(f.face, text)
+	const margin = 4
+	img := image.NewGray(image.Rect(0, 0, (bounds.Max.X-bounds.Min.X).Ceil()+2*margin, (bounds.Max.Y-bounds.Min.Y).Ceil()+2*margin))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.Black,
+		Face: f.face,
+		Dot:  fixed.Point26_6{X: fixed.I(margin) - bounds.Min.X, Y: fixed.I(margin) - bounds.Min.Y},
+	}
+	d.DrawString(text)
+
+	shape = NewShape(img)
+	return shape, shape != nil
+}
+
+// Shape is the outline of a glyph independent of its size and pen: the
+// distance to the nearest ink over the glyph scaled into a square grid,
+// keeping its proportions
+type Shape struct {
+	field []float64
+}
+
+// NewShape measures the ink of a glyph image or a rendered character.
+// It returns nil if img has no ink.
+func NewShape(img image.Image) *Shape {
+	mask := imaging.NewMask(img, imaging.GlyphInkThreshold)
+	ink := mask.InkBounds()
+	if ink.Empty() {
+		return nil
+	}
+
+	// The longer side scaled to the grid and centred; a grid pixel is ink if
+	// any pixel it covers is, so thin strokes stay connected
+	scale := float64(shapeSize) / float64(max(ink.Dx(), ink.Dy()))
+	x0 := (shapeSize - int(math.Ceil(float64(ink.Dx())*scale))) / 2
+	y0 := (shapeSize - int(math.Ceil(float64(ink.Dy())*scale))) / 2
+	grid := image.NewAlpha(image.Rect(0, 0, shapeSize, shapeSize))
+	for y := ink.Min.Y; y < ink.Max.Y; y++ {
+		for x := ink.Min.X; x < ink.Max.X; x++ {
+			if mask.At(x, y) {
+				gx := min(shapeSize-1, x0+int(float64(x-ink.Min.X)*scale))
+				gy := min(shapeSize-1, y0+int(float64(y-ink.Min.Y)*scale))
+				grid.SetAlpha(gx, gy, color.Alpha{A: 0xFF})
+			}
+		}
+	}
+
+	field := imaging.InkDistance(imaging.NewMask(grid, imaging.GlyphInkThreshold))
+	for i, d := range field {
+		field[i] = min(d, maxDistance)
+	}
+	return &Shape{field: field}
+}
+
+// Similarity compares two shapes by the correlation of their distance
+// fields: 1 for the same shape, around 0 for unrelated ones
+func Similarity(a, b *Shape) float64 {
+	var meanA, meanB float64
+	for i := range a.field {
+		meanA += a.field[i]
+		meanB += b.field[i]
+	}
+	meanA /= float64(len(a.field))
+	meanB /= float64(len(b.field))
+
+	var cov, varA, varB float64
+	for i := range a.field {
+		da, db := a.field[i]-meanA, b.field[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"glyph_extractor/manifest"
+	"glyph_extractor/scan"
+)
+
+// Sample texts rendered in the report, covering the Czech and English letters
+var reportPangrams = []string{
+	"Příliš žluťoučký kůň úpěl ďábelské ódy.",
+	"The quick brown fox jumps over the lazy dog.",
+	"0123456789 (1 + 2 = 3) 50 % € $ @ & ? !",
+}
+
+// Sizes of the images embedded in the report, in pixels
+const (
+	reportPageWidth  = 1200 // Scanned pages
+	reportCellHeight = 110  // Cell crops and glyphs
+	reportLineHeight = 90   // One line of sample text
+)
+
+// reportData is what the report template is filled with
+type reportData struct {
+	Title     string
+	Generated string
+	Cells     int
+	Glyphs    int
+	Empty     int
+	Missing   []string // Charset entries without a glyph that were not blank cells
+	Shifts    []string // How to fix cells that hold the wrong characters
+	NoScans   string   // Why the scanned pages are not shown, if they aren't
+	Samples   []reportSample
+	Sheet     template.URL
+	Pages     []reportPage
+	Flagged   []reportCell
+	All       []reportCell
+}
+
+type reportSample struct {
+	Text    string
+	Image   template.URL
+	Missing string // Characters the set has no glyph for
+}
+
+type reportPage struct {
+	Name  string
+	Image template.URL
+}
+
+// reportCell is one row of the cell tables
+type reportCell struct {
+	Char     string
+	Place    string
+	Crop     template.URL // The cell as scanned, with the cut drawn in
+	Glyph    template.URL // The glyph image that was saved
+	Metrics  string
+	Empty    bool
+	Warnings []string
+}
+
+// dataURL embeds an image in the report, as JPEG for photos of pages and PNG
+// for anything that needs transparency or sharp edges
+func dataURL(img image.Image, asJPEG bool) (template.URL, error) {
+	var buf bytes.Buffer
+	mime := "image/png"
+	var err error
+	if asJPEG {
+		mime = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// scaleImage resizes img by factor; images are never enlarged
+func scaleImage(img image.Image, factor float64) image.Image {
+	if factor >= 1 {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(b.Dx())*factor)), max(1, int(float64(b.Dy())*factor))))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// cellScan returns the scan file and the page within it a cell was cut from.
+// Reports written before the two were recorded only name the file in Source.
+func cellScan(cell manifest.Cell) (path string, page int) {
+	if cell.Scan != "" {
+		return cell.Scan, max(1, cell.ScanPage)
+	}
+	return cell.Source, 1
+}
+
+// loadCellPages loads the scan pages named in a cell report, by page number
+func loadCellPages(cells []manifest.Cell, rotation int) (map[int]image.Image, error) {
+	loaded := make(map[string][]scan.Page)
+	pages := make(map[int]image.Image)
+	for _, cell := range cells {
+		if _, ok := pages[cell.Page]; ok {
+			continue
+		}
+		path, number := cellScan(cell)
+		if path == "" {
+			return nil, fmt.Errorf("cells.json does not name the scan of page %d", cell.Page)
+		}
+		if _, ok := loaded[path]; !ok {
+			p, err := scan.Load(path, rotation)
+			if err != nil {
+				return nil, err
+			}
+			loaded[path] = p
+		}
+		p := loaded[path]
+		if number > len(p) {
+			return nil, fmt.Errorf("%s has no page %d", path, number)
+		}
+		pages[cell.Page] = p[number-1].Image
+	}
+	return pages, nil
+}
+
+// cellCrop cuts a cell out of its scan and frames the glyph image that was
+// taken from it
+func cellCrop(page image.Image, cell manifest.Cell) *image.RGBA {
+	rect := cell.Rect.Rectangle()
+	crop := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(crop, crop.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(crop, crop.Bounds(), page, rect.Min, draw.Src)
+	if !cell.Empty {
+		strokeRect(crop, cell.Trim.Rectangle().Sub(rect.Min), max(1, rect.Dx()/100), previewBoxColor)
+	}
+	return crop
+}
+
+// cellMetrics describes the measurements of an extracted cell in words
+func cellMetrics(cell manifest.Cell, metrics manifest.GlyphMetrics, found bool) string {
+	blobs := "blobs"
+	if cell.Components == 1 {
+		blobs = "blob"
+	}
+	parts := []string{
+		fmt.Sprintf("ink %.1f %%", cell.InkRatio*100),
+		fmt.Sprintf("%d %s", cell.Components, blobs),
+	}
+	if found {
+		parts = append([]string{
+			fmt.Sprintf("%d×%d px", metrics.Width, metrics.Height),
+			fmt.Sprintf("baseline %d", metrics.Baseline),
+		}, parts...)
+		if metrics.StrokeWidth > 0 {
+			parts = append(parts, fmt.Sprintf("pen %.1f px", metrics.StrokeWidth))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// buildReport collects everything the report shows about a glyph set.
+// pages may be nil when the scans are not available.
+func buildReport(set *manifest.GlyphSet, pages map[int]image.Image, opts PreviewOptions) (*reportData, error) {
+	data := &reportData{
+		Generated: time.Now().Format("2 January 2006 15:04"),
+		Glyphs:    len(set.Glyphs),
+		Empty:     len(set.Manifest.Empty),
+	}
+
+	for _, shift := range set.Manifest.Shifts {
+		data.Shifts = append(data.Shifts, shift.Suggestion)
+	}
+
+	// Every warning of the contact sheet, by glyph key
+	warnings := make(map[string][]string)
+	for _, t := range previewTiles(set) {
+		warnings[t.key] = t.warnings
+		if t.glyph == nil && !t.empty {
+			data.Missing = append(data.Missing, t.key)
+		}
+	}
+
+	// Sample text at about one line per reportLineHeight pixels
+	lineHeight, _ := set.LineBox()
+	renderOpts := DefaultRenderOptions()
+	renderOpts.Scale = float64(reportLineHeight) / float64(max(lineHeight, 1))
+	renderOpts.MaxWidth = reportPageWidth
+	renderOpts.Background = color.White
+	for _, text := range reportPangrams {
+		renderer := NewRenderer(set, renderOpts)
+		url, err := dataURL(renderer.Render(text), false)
+		if err != nil {
+			return nil, err
+		}
+		var missing []string
+		for _, ch := range renderer.Missing() {
+			missing = append(missing, string(ch))
+		}
+		data.Samples = append(data.Samples, reportSample{Text: text, Image: url, Missing: strings.Join(missing, " ")})
+	}
+
+	sheet, err := dataURL(scaleImage(Preview(set, opts), float64(reportPageWidth)/float64(opts.Columns*opts.TileSize)), false)
+	if err != nil {
+		return nil, err
+	}
+	data.Sheet = sheet
+
+	if set.Cells == nil {
+		// Renamed or hand-made sets have glyphs only
+		for _, key := range set.Keys() {
+			glyph := set.Glyphs[key]
+			url, err := dataURL(scaleImage(proofImage(set, glyph), float64(reportCellHeight)/float64(max(glyph.Image.Bounds().Dy(), 1))), false)
+			if err != nil {
+				return nil, err
+			}
+			row := reportCell{Char: key, Glyph: url, Warnings: warnings[key]}
+			row.Metrics = fmt.Sprintf("%d×%d px, baseline %d", glyph.Metrics.Width, glyph.Metrics.Height, glyph.Metrics.Baseline)
+			data.All = append(data.All, row)
+			if len(row.Warnings) > 0 {
+				data.Flagged = append(data.Flagged, row)
+			}
+		}
+		return data, nil
+	}
+
+	cells := set.Cells.Cells
+	data.Cells = len(cells)
+	flagged := func(cell manifest.Cell) bool { return cell.Empty || len(warnings[cell.Char]) > 0 }
+
+	// Pages with their cells marked, in page order
+	byPage := make(map[int][]manifest.Cell)
+	var numbers []int
+	for _, cell := range cells {
+		if _, ok := byPage[cell.Page]; !ok {
+			numbers = append(numbers, cell.Page)
+		}
+		byPage[cell.Page] = append(byPage[cell.Page], cell)
+	}
+	sort.Ints(numbers)
+	grid := set.Manifest.Grid()
+	face := overlayFace(grid.DPI)
+	for _, n := range numbers {
+		page, ok := pages[n]
+		if !ok {
+			continue
+		}
+		overlay := pageOverlay(page, byPage[n], grid, face, flagged)
+		url, err := dataURL(scaleImage(overlay, float64(reportPageWidth)/float64(overlay.Bounds().Dx())), true)
+		if err != nil {
+			return nil, err
+		}
+		data.Pages = append(data.Pages, reportPage{Name: fmt.Sprintf("Page %d: %s", n, filepath.Base(byPage[n][0].Source)), Image: url})
+	}
+
+	for _, cell := range cells {
+		row := reportCell{
+			Char:     cell.Char,
+			Place:    fmt.Sprintf("page %d, row %d, column %d", cell.Page, cell.Row+1, cell.Column+1),
+			Empty:    cell.Empty,
+			Warnings: warnings[cell.Char],
+		}
+		if cell.Empty {
+			row.Warnings = nil // Already said by Empty
+		}
+		factor := float64(reportCellHeight) / float64(max(cell.Rect.Height, 1))
+
+		if page, ok := pages[cell.Page]; ok {
+			url, err := dataURL(scaleImage(cellCrop(page, cell), factor), false)
+			if err != nil {
+				return nil, err
+			}
+			row.Crop = url
+		}
+		glyph, found := set.Glyphs[cell.Char]
+		if found && !cell.Empty {
+			// Same scale as the crop so the two compare side by side
+			url, err := dataURL(scaleImage(proofImage(set, glyph), factor), false)
+			if err != nil {
+				return nil, err
+			}
+			row.Glyph = url
+		}
+		var metrics manifest.GlyphMetrics
+		if found {
+			metrics = glyph.Metrics
+		}
+		row.Metrics = cellMetrics(cell, metrics, found)
+
+		data.All = append(data.All, row)
+		if flagged(cell) {
+			data.Flagged = append(data.Flagged, row)
+		}
+	}
+	return data, nil
+}
+
+// reportTemplate is the QA report page; it needs no network access
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Handwriting check: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 1240px; margin: 2em auto; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.generated { color: #777; margin-top: 0; }
+.summary { font-size: 1.15em; padding: 0.8em 1em; border-radius: 6px; background: #eef6ee; }
+.summary.attention { background: #fdeeee; }
+img { max-width: 100%; }
+.sample { margin: 1em 0 2em; }
+.sample p { margin: 0.2em 0; color: #555; }
+.missing { color: #c00; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: middle; }
+td.char { font-size: 1.8em; text-align: center; width: 2.5em; }
+td.image { width: 160px; text-align: center; }
+td.image img { background: repeating-conic-gradient(#eee 0 25%, #fff 0 50%) 0 0 / 16px 16px; }
+tr.flagged { background: #fdeeee; }
+.warning { color: #c00; margin: 0.2em 0; }
+.note { color: #777; }
+.legend span { display: inline-block; width: 1em; height: 1em; vertical-align: middle; margin: 0 0.3em 0 1em; }
+</style>
+</head>
+<body>
+<h1>Handwriting check: {{.Title}}</h1>
+<p class="generated">Created {{.Generated}}</p>
+
+{{if or .Flagged .Missing}}
+<p class="summary attention">{{.Glyphs}} letters and signs were cut out{{if .Cells}} of {{.Cells}} boxes{{end}}.
+{{len .Flagged}} boxes need a look before the set is approved{{if .Empty}}, {{.Empty}} of them left blank{{end}}{{if .Missing}}; {{len .Missing}} signs are missing{{end}}.</p>
+{{range .Shifts}}
+<p class="summary attention">Characters were written in the wrong boxes: {{.}}.</p>
+{{end}}
+{{else}}
+<p class="summary">{{.Glyphs}} letters and signs were cut out{{if .Cells}} of {{.Cells}} boxes{{end}} and none of them look wrong.</p>
+{{end}}
+
+<h2>Sample text</h2>
+<p>This is how text written with the set looks. Read it through: every letter should look like the writer's own.</p>
+{{range .Samples}}
+<div class="sample">
+<p>{{.Text}}</p>
+<img src="{{.Image}}" alt="{{.Text}}">
+{{if .Missing}}<p class="missing">No glyph for: {{.Missing}}</p>{{end}}
+</div>
+{{end}}
+
+{{if .Flagged}}
+<h2>Boxes to check</h2>
+<p>On the left is the box as it was scanned, with a blue frame around the part that was cut out. On the right is the cut-out letter.</p>
+<table>
+<tr><th>Sign</th><th>Scanned</th><th>Cut out</th><th>Problem</th></tr>
+{{range .Flagged}}{{template "row" .}}{{end}}
+</table>
+{{end}}
+
+{{if .Missing}}
+<h2>Missing</h2>
+<p class="missing">{{range .Missing}}{{.}} {{end}}</p>
+{{end}}
+
+<h2>All letters</h2>
+<img src="{{.Sheet}}" alt="Contact sheet">
+
+{{if .Pages}}
+<h2>Scanned pages</h2>
+<p class="legend"><span style="background: rgb(0,160,60)"></span>box<span style="background: rgb(40,110,255)"></span>cut out<span style="background: rgb(230,0,200)"></span>line to write on<span style="background: rgb(220,30,30)"></span>needs a look</p>
+{{range .Pages}}
+<h3>{{.Name}}</h3>
+<img src="{{.Image}}" alt="{{.Name}}">
+{{end}}
+{{else if .NoScans}}
+<h2>Scanned pages</h2>
+<p class="note">The scans are not shown: {{.NoScans}}</p>
+{{end}}
+
+<h2>Every box</h2>
+<table>
+<tr><th>Sign</th><th>Scanned</th><th>Cut out</th><th>Details</th></tr>
+{{range .All}}{{template "row" .}}{{end}}
+</table>
+</body>
+</html>
+{{define "row"}}<tr{{if or .Empty .Warnings}} class="flagged"{{end}}>
+<td class="char">{{.Char}}</td>
+<td class="image">{{if .Crop}}<img src="{{.Crop}}" alt="Scan of {{.Char}}">{{else}}<span class="note">no scan</span>{{end}}</td>
+<td class="image">{{if .Glyph}}<img src="{{.Glyph}}" alt="{{.Char}}">{{else if .Empty}}<span class="note">left blank</span>{{else}}<span class="note">none</span>{{end}}</td>
+<td>{{if .Place}}<div class="note">{{.Place}}</div>{{end}}
+{{if .Empty}}<p class="warning">The box was left blank</p>{{end}}
+{{range .Warnings}}<p class="warning">{{.}}</p>{{end}}
+<div class="note">{{.Metrics}}</div></td>
+</tr>
+{{end}}`))
+
+// runReport implements the report subcommand
+func runReport(args []string) error {
+	var output, inputFiles, orientationName, fontPath string
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.StringVar(&output, "output", "", "Output HTML file (default report.html next to glyphs.json)")
+	fs.StringVar(&inputFiles, "input", "", "The scans the set was extracted from, if they moved (comma-separated, default: as recorded in cells.json)")
+	fs.StringVar(&orientationName, "orientation", "auto", "Page orientation the set was extracted with")
+	fs.StringVar(&fontPath, "font", defaultFontPath, "TrueType font for the contact sheet labels")
+	fs.Usage = func() {
+		fmt.Println("Usage: glyph_extractor report [options] <glyphs_dir>")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	rotation, err := scan.ParseOrientation(orientationName)
+	if err != nil {
+		return err
+	}
+	manifestPath, err := manifest.Find(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	set, err := manifest.LoadGlyphSet(manifestPath)
+	if err != nil {
+		return err
+	}
+	opts := PreviewOptions{Columns: 10, TileSize: 120}
+	opts.Face, err = loadFace(fontPath, 13)
+	if err != nil {
+		return fmt.Errorf("loading label font: %w", err)
+	}
+
+	// The scans are optional: without them the report shows the glyphs only
+	var pages map[int]image.Image
+	var noScans string
+	if set.Cells == nil {
+		noScans = "the set has no cells.json"
+	} else {
+		cells := set.Cells.Cells
+		if inputFiles != "" {
+			cells = withSources(cells, strings.Split(inputFiles, ","))
+		}
+		pages, err = loadCellPages(cells, rotation)
+		if err != nil {
+			noScans = err.Error()
+			fmt.Printf("Warning: %v; the report shows the glyphs only (use -input)\n", err)
+		}
+		set.Cells.Cells = cells
+	}
+
+	data, err := buildReport(set, pages, opts)
+	if err != nil {
+		return err
+	}
+	data.Title = filepath.Base(filepath.Dir(manifestPath))
+	data.NoScans = noScans
+
+	if output == "" {
+		output = filepath.Join(filepath.Dir(manifestPath), "report.html")
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := reportTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("writing report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Report: %s (%d glyphs, %d to check)\n", output, data.Glyphs, len(data.Flagged))
+	return nil
+}
+
+// withSources replaces the recorded scan of every cell with the given files.
+// Each file stands for one file of the extraction input, in order: a run of
+// pages numbered up from one file, so a PDF is one file and a scan passed
+// twice is two.
+func withSources(cells []manifest.Cell, files []string) []manifest.Cell {
+	byPage := make(map[int]manifest.Cell)
+	var numbers []int
+	for _, cell := range cells {
+		if _, ok := byPage[cell.Page]; !ok {
+			byPage[cell.Page] = cell
+			numbers = append(numbers, cell.Page)
+		}
+	}
+	sort.Ints(numbers)
+
+	input := make(map[int]int) // Page number -> index into files
+	n, lastPath, lastPage := -1, "", 0
+	for _, number := range numbers {
+		path, page := cellScan(byPage[number])
+		if path != lastPath || page <= lastPage {
+			n++
+		}
+		input[number] = n
+		lastPath, lastPage = path, page
+	}
+
+	out := make([]manifest.Cell, len(cells))
+	for i, cell := range cells {
+		if n := input[cell.Page]; n < len(files) {
+			_, cell.ScanPage = cellScan(cell)
+			cell.Scan = strings.TrimSpace(files[n])
+		}
+		out[i] = cell
+	}
+	return out
+}
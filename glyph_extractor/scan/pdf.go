@@ -0,0 +1,895 @@
+package scan
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// This is a deliberately small PDF reader: enough to pull the scanned raster
+// image out of every page of a scanner PDF. It finds objects by scanning the
+// file rather than trusting the xref table, which also copes with the slightly
+// broken files some scanners write.
+
+// pdfName is a PDF name object without the leading slash
+type pdfName string
+
+// pdfRef is an indirect object reference
+type pdfRef struct {
+	Num, Gen int
+}
+
+type pdfDict map[pdfName]any
+
+type pdfArray []any
+
+// pdfStream is a stream object with its still encoded data
+type pdfStream struct {
+	Dict pdfDict
+	Data []byte
+}
+
+// pdfFile holds every object of a PDF by object number
+type pdfFile struct {
+	objects map[int]any
+}
+
+// objectHeader matches the start of an indirect object, e.g. "12 0 obj"
+var objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// openPDF reads a PDF and indexes all of its objects, including the ones
+// packed into object streams
+func openPDF(path string) (*pdfFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	f := &pdfFile{objects: make(map[int]any)}
+	next := 0
+	for _, m := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		// Skip matches inside the data of a stream we already read
+		if m[0] < next {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+
+		p := &pdfParser{data: data, pos: m[1]}
+		value, err := p.value()
+		if err != nil {
+			continue
+		}
+		p.skipSpace()
+
+		if dict, ok := value.(pdfDict); ok && p.keyword("stream") {
+			stream, end := f.readStream(data, p.pos, dict)
+			value, p.pos = stream, end
+		}
+		f.objects[num] = value
+		next = p.pos
+	}
+
+	// Objects stored inside object streams
+	for _, obj := range f.objects {
+		if s, ok := obj.(*pdfStream); ok && s.Dict["Type"] == pdfName("ObjStm") {
+			f.readObjectStream(s)
+		}
+	}
+
+	if len(f.objects) == 0 {
+		return nil, fmt.Errorf("no objects found")
+	}
+	return f, nil
+}
+
+// readStream extracts stream data starting right after the "stream" keyword
+func (f *pdfFile) readStream(data []byte, pos int, dict pdfDict) (*pdfStream, int) {
+	// The keyword is followed by CRLF or LF
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+
+	// Trust /Length only when it is direct and lands on "endstream"
+	if length, ok := dict["Length"].(int); ok && length >= 0 && pos+length <= len(data) {
+		rest := bytes.TrimLeft(data[pos+length:], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return &pdfStream{Dict: dict, Data: data[pos : pos+length]}, pos + length
+		}
+	}
+
+	end := bytes.Index(data[pos:], []byte("endstream"))
+	if end < 0 {
+		return &pdfStream{Dict: dict, Data: data[pos:]}, len(data)
+	}
+	stream := bytes.TrimRight(data[pos:pos+end], "\r\n")
+	return &pdfStream{Dict: dict, Data: stream}, pos + end
+}
+
+// readObjectStream adds the objects packed in an object stream. Objects that
+// also exist uncompressed keep the uncompressed version.
+func (f *pdfFile) readObjectStream(s *pdfStream) {
+	data, err := f.decodeStream(s)
+	if err != nil {
+		return
+	}
+	n, _ := f.resolve(s.Dict["N"]).(int)
+	first, _ := f.resolve(s.Dict["First"]).(int)
+
+	header := &pdfParser{data: data}
+	for i := 0; i < n; i++ {
+		num, err1 := header.value()
+		offset, err2 := header.value()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		objNum, ok1 := num.(int)
+		objOffset, ok2 := offset.(int)
+		if !ok1 || !ok2 || first+objOffset >= len(data) {
+			return
+		}
+		if _, exists := f.objects[objNum]; exists {
+			continue
+		}
+		p := &pdfParser{data: data, pos: first + objOffset}
+		if value, err := p.value(); err == nil {
+			f.objects[objNum] = value
+		}
+	}
+}
+
+// resolve follows indirect references
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.Num]
+	}
+	return nil
+}
+
+// dict resolves v and returns it as a dictionary; streams yield their dictionary
+func (f *pdfFile) dict(v any) pdfDict {
+	switch v := f.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.Dict
+	}
+	return nil
+}
+
+// number resolves v as a number
+func (f *pdfFile) number(v any) (float64, bool) {
+	switch v := f.resolve(v).(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// pdfPage is a page of the document with its inherited attributes
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+	mediaBox  [4]float64
+	rotate    int
+}
+
+// pages returns the pages of the document in order
+func (f *pdfFile) pages() ([]pdfPage, error) {
+	// Incremental updates append a new catalog with a higher object number
+	var catalog pdfDict
+	catalogNum := -1
+	for num, obj := range f.objects {
+		if d := f.dict(obj); d != nil && d["Type"] == pdfName("Catalog") && num > catalogNum {
+			catalog, catalogNum = d, num
+		}
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("no document catalog")
+	}
+
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(node any, inherited pdfPage) error
+	walk = func(node any, inherited pdfPage) error {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.Num] {
+				return fmt.Errorf("page tree loops")
+			}
+			visited[ref.Num] = true
+		}
+		d := f.dict(node)
+		if d == nil {
+			return nil
+		}
+
+		// Resources, MediaBox and Rotate are inherited down the page tree
+		if res := f.dict(d["Resources"]); res != nil {
+			inherited.resources = res
+		}
+		if box, ok := f.resolve(d["MediaBox"]).(pdfArray); ok && len(box) == 4 {
+			for i := range box {
+				inherited.mediaBox[i], _ = f.number(box[i])
+			}
+		}
+		if rotate, ok := f.number(d["Rotate"]); ok {
+			inherited.rotate = (int(rotate)%360 + 360) % 360
+		}
+
+		if d["Type"] == pdfName("Page") {
+			inherited.dict = d
+			pages = append(pages, inherited)
+			return nil
+		}
+		kids, _ := f.resolve(d["Kids"]).(pdfArray)
+		for _, kid := range kids {
+			if err := walk(kid, inherited); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(catalog["Pages"], pdfPage{mediaBox: [4]float64{0, 0, 612, 792}}); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("document has no pages")
+	}
+	return pages, nil
+}
+
+// drawOperator matches an XObject being painted in a content stream, e.g. "/Im1 Do"
+var drawOperator = regexp.MustCompile(`/([^\s/\[\]()<>{}%]+)\s+Do\b`)
+
+// drawnXObjects returns the names of the XObjects a page's content paints.
+// Writers often share one resource dictionary between all pages, so the
+// resources alone don't tell which image belongs to which page.
+func (f *pdfFile) drawnXObjects(page pdfPage) map[pdfName]bool {
+	contents := f.resolve(page.dict["Contents"])
+	streams, ok := contents.(pdfArray)
+	if !ok {
+		streams = pdfArray{contents}
+	}
+
+	names := make(map[pdfName]bool)
+	for _, v := range streams {
+		s, ok := f.resolve(v).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := f.decodeStream(s)
+		if err != nil {
+			continue
+		}
+		for _, m := range drawOperator.FindAllSubmatch(data, -1) {
+			names[pdfName(m[1])] = true
+		}
+	}
+	return names
+}
+
+// pageImage returns the largest raster image drawn on a page — the scan
+func (f *pdfFile) pageImage(page pdfPage) (*pdfStream, error) {
+	xobjects := f.dict(page.resources["XObject"])
+	drawn := f.drawnXObjects(page)
+	var best *pdfStream
+	bestArea := 0
+	for name, v := range xobjects {
+		if len(drawn) > 0 && !drawn[name] {
+			continue
+		}
+		s, ok := f.resolve(v).(*pdfStream)
+		if !ok || s.Dict["Subtype"] != pdfName("Image") {
+			continue
+		}
+		w, _ := f.number(s.Dict["Width"])
+		h, _ := f.number(s.Dict["Height"])
+		if area := int(w) * int(h); area > bestArea {
+			best, bestArea = s, area
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("page has no raster image")
+	}
+	return best, nil
+}
+
+// filters returns the filter chain of a stream with the parameters of each
+func (f *pdfFile) filters(s *pdfStream) ([]pdfName, []pdfDict) {
+	var names []pdfName
+	var params []pdfDict
+	switch v := f.resolve(s.Dict["Filter"]).(type) {
+	case pdfName:
+		names = []pdfName{v}
+		params = []pdfDict{f.dict(s.Dict["DecodeParms"])}
+	case pdfArray:
+		parms, _ := f.resolve(s.Dict["DecodeParms"]).(pdfArray)
+		for i, name := range v {
+			n, _ := f.resolve(name).(pdfName)
+			names = append(names, n)
+			var p pdfDict
+			if i < len(parms) {
+				p = f.dict(parms[i])
+			}
+			params = append(params, p)
+		}
+	}
+	return names, params
+}
+
+// decodeStream applies the stream's filters. It stops before DCTDecode, which
+// is left to the JPEG decoder.
+func (f *pdfFile) decodeStream(s *pdfStream) ([]byte, error) {
+	data := s.Data
+	names, params := f.filters(s)
+	for i, name := range names {
+		switch name {
+		case "FlateDecode", "Fl":
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("flate: %w", err)
+			}
+			decoded, err := io.ReadAll(r)
+			if err != nil && len(decoded) == 0 {
+				return nil, fmt.Errorf("flate: %w", err)
+			}
+			data, err = f.unpredict(decoded, params[i])
+			if err != nil {
+				return nil, err
+			}
+		case "DCTDecode", "DCT":
+			if i != len(names)-1 {
+				return nil, fmt.Errorf("DCTDecode must be the last filter")
+			}
+			return data, nil
+		default:
+			return nil, fmt.Errorf("unsupported filter %s", name)
+		}
+	}
+	return data, nil
+}
+
+// unpredict reverses the PNG predictors a Flate stream may use
+func (f *pdfFile) unpredict(data []byte, params pdfDict) ([]byte, error) {
+	predictor, _ := f.number(params["Predictor"])
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, fmt.Errorf("TIFF predictor is not supported")
+		}
+		return data, nil
+	}
+
+	columns, colors, bpc := 1.0, 1.0, 8.0
+	if v, ok := f.number(params["Columns"]); ok {
+		columns = v
+	}
+	if v, ok := f.number(params["Colors"]); ok {
+		colors = v
+	}
+	if v, ok := f.number(params["BitsPerComponent"]); ok {
+		bpc = v
+	}
+	bpp := max(1, int(math.Ceil(colors*bpc/8)))
+	rowLen := int(math.Ceil(columns * colors * bpc / 8))
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos < len(data); pos += rowLen + 1 {
+		filter := data[pos]
+		row := make([]byte, rowLen)
+		copy(row, data[pos+1:min(len(data), pos+1+rowLen)])
+		for i := range row {
+			var left, up, upLeft int
+			if i >= bpp {
+				left = int(row[i-bpp])
+				upLeft = int(prev[i-bpp])
+			}
+			up = int(prev[i])
+			switch filter {
+			case 1:
+				row[i] += byte(left)
+			case 2:
+				row[i] += byte(up)
+			case 3:
+				row[i] += byte((left + up) / 2)
+			case 4:
+				row[i] += byte(paeth(left, up, upLeft))
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth is the PNG Paeth predictor
+func paeth(a, b, c int) int {
+	p := a + b - c
+	pa, pb, pc := p-a, p-b, p-c
+	if pa < 0 {
+		pa = -pa
+	}
+	if pb < 0 {
+		pb = -pb
+	}
+	if pc < 0 {
+		pc = -pc
+	}
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// decodeImage turns an image XObject into an image
+func (f *pdfFile) decodeImage(s *pdfStream) (image.Image, error) {
+	data, err := f.decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+
+	names, _ := f.filters(s)
+	if len(names) > 0 && (names[len(names)-1] == "DCTDecode" || names[len(names)-1] == "DCT") {
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+
+	w, _ := f.number(s.Dict["Width"])
+	h, _ := f.number(s.Dict["Height"])
+	bpc, ok := f.number(s.Dict["BitsPerComponent"])
+	if !ok {
+		bpc = 8
+	}
+	width, height, bits := int(w), int(h), int(bpc)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	components, palette, err := f.colorSpace(s.Dict["ColorSpace"])
+	if err != nil {
+		return nil, err
+	}
+	if bits != 1 && bits != 8 && bits != 16 && !(palette != nil && (bits == 2 || bits == 4)) {
+		return nil, fmt.Errorf("unsupported %d bits per component", bits)
+	}
+
+	// Decode [1 0] inverts bilevel and grayscale scans
+	invert := false
+	if decode, ok := f.resolve(s.Dict["Decode"]).(pdfArray); ok && len(decode) >= 2 {
+		d0, _ := f.number(decode[0])
+		d1, _ := f.number(decode[1])
+		invert = d0 > d1
+	}
+
+	rowBytes := (width*components*bits + 7) / 8
+	if len(data) < rowBytes*height {
+		return nil, fmt.Errorf("image data too short")
+	}
+
+	// sample reads component c of pixel x in a row, scaled to 0-255
+	sample := func(row []byte, x, c int) uint8 {
+		i := x*components + c
+		switch bits {
+		case 16:
+			return row[2*i]
+		case 8:
+			return row[i]
+		default:
+			shift := 8 - bits - (i*bits)%8
+			v := (row[i*bits/8] >> shift) & (1<<bits - 1)
+			if palette != nil {
+				return v
+			}
+			return v * uint8(255/(1<<bits-1))
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := data[y*rowBytes : (y+1)*rowBytes]
+		for x := 0; x < width; x++ {
+			var c color.RGBA
+			switch {
+			case palette != nil:
+				idx := int(sample(row, x, 0))
+				if idx < len(palette) {
+					c = palette[idx]
+				}
+			case components == 1:
+				v := sample(row, x, 0)
+				if invert {
+					v = 255 - v
+				}
+				c = color.RGBA{v, v, v, 255}
+			case components == 3:
+				c = color.RGBA{sample(row, x, 0), sample(row, x, 1), sample(row, x, 2), 255}
+			case components == 4:
+				r, g, b := color.CMYKToRGB(sample(row, x, 0), sample(row, x, 1), sample(row, x, 2), sample(row, x, 3))
+				c = color.RGBA{r, g, b, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// colorSpace returns the number of components of a colour space, and the
+// palette for indexed colour
+func (f *pdfFile) colorSpace(v any) (int, []color.RGBA, error) {
+	switch cs := f.resolve(v).(type) {
+	case nil:
+		return 1, nil, nil // Image masks and missing colour spaces
+	case pdfName:
+		switch cs {
+		case "DeviceGray", "G", "CalGray":
+			return 1, nil, nil
+		case "DeviceRGB", "RGB", "CalRGB":
+			return 3, nil, nil
+		case "DeviceCMYK", "CMYK":
+			return 4, nil, nil
+		}
+		return 0, nil, fmt.Errorf("unsupported colour space %s", cs)
+	case pdfArray:
+		if len(cs) == 0 {
+			break
+		}
+		switch f.resolve(cs[0]) {
+		case pdfName("ICCBased"):
+			if len(cs) > 1 {
+				if n, ok := f.number(f.dict(cs[1])["N"]); ok {
+					return int(n), nil, nil
+				}
+			}
+		case pdfName("CalGray"):
+			return 1, nil, nil
+		case pdfName("CalRGB"):
+			return 3, nil, nil
+		case pdfName("Indexed"):
+			if len(cs) < 4 {
+				break
+			}
+			base, _, err := f.colorSpace(cs[1])
+			if err != nil {
+				return 0, nil, err
+			}
+			var lookup []byte
+			switch l := f.resolve(cs[3]).(type) {
+			case string:
+				lookup = []byte(l)
+			case *pdfStream:
+				if lookup, err = f.decodeStream(l); err != nil {
+					return 0, nil, err
+				}
+			}
+			var palette []color.RGBA
+			for i := 0; i+base <= len(lookup); i += base {
+				switch base {
+				case 1:
+					palette = append(palette, color.RGBA{lookup[i], lookup[i], lookup[i], 255})
+				case 3:
+					palette = append(palette, color.RGBA{lookup[i], lookup[i+1], lookup[i+2], 255})
+				case 4:
+					r, g, b := color.CMYKToRGB(lookup[i], lookup[i+1], lookup[i+2], lookup[i+3])
+					palette = append(palette, color.RGBA{r, g, b, 255})
+				}
+			}
+			return 1, palette, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("unsupported colour space")
+}
+
+// pdfParser reads PDF object syntax
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+// isPDFSpace reports PDF whitespace characters
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isPDFDelimiter reports characters that end a name or keyword
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// keyword consumes kw if it comes next
+func (p *pdfParser) keyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if end > len(p.data) || string(p.data[p.pos:end]) != kw {
+		return false
+	}
+	if end < len(p.data) && !isPDFDelimiter(p.data[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// value parses the next PDF object
+func (p *pdfParser) value() (any, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict := pdfDict{}
+		for {
+			p.skipSpace()
+			if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+				p.pos += 2
+				return dict, nil
+			}
+			key, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key is not a name")
+			}
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			dict[name] = val
+		}
+	case c == '<':
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		var arr pdfArray
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+	case c == '(':
+		return p.literalString()
+	case c == '/':
+		return p.name(), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+
+	for _, kw := range []string{"true", "false", "null"} {
+		if p.keyword(kw) {
+			switch kw {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", p.data[p.pos], p.pos)
+}
+
+// name parses a name object, decoding #xx escapes
+func (p *pdfParser) name() pdfName {
+	p.pos++
+	var name []byte
+	for p.pos < len(p.data) && !isPDFDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		p.pos++
+	}
+	return pdfName(name)
+}
+
+// number parses a number, or an indirect reference "num gen R"
+func (p *pdfParser) number() (any, error) {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	token := string(p.data[start:p.pos])
+
+	n, err := strconv.Atoi(token)
+	if err != nil {
+		v, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return v, nil
+	}
+
+	// Look ahead for "gen R"
+	save := p.pos
+	p.skipSpace()
+	genStart := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos > genStart {
+		gen, _ := strconv.Atoi(string(p.data[genStart:p.pos]))
+		if p.keyword("R") {
+			return pdfRef{Num: n, Gen: gen}, nil
+		}
+	}
+	p.pos = save
+	return n, nil
+}
+
+// literalString parses a (string) with nested parentheses and escapes
+func (p *pdfParser) literalString() (any, error) {
+	p.pos++
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(out), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// hexString parses a <hex> string
+func (p *pdfParser) hexString() (any, error) {
+	p.pos++
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex string")
+		}
+		out[i] = byte(v)
+	}
+	return string(out), nil
+}
+
+// rotationOrientations maps a clockwise /Rotate to the TIFF/EXIF orientation
+// that turns the image upright
+var rotationOrientations = map[int]int{0: 1, 90: 6, 180: 3, 270: 8}
+
+// loadPDFPages extracts the scanned image of every page of a PDF
+func loadPDFPages(path string) ([]Page, error) {
+	f, err := openPDF(path)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := f.pages()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Page, 0, len(pages))
+	for i, page := range pages {
+		s, err := f.pageImage(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		img, err := f.decodeImage(s)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+
+		// The scan fills the page, so its resolution follows from the page width
+		widthPt := page.mediaBox[2] - page.mediaBox[0]
+		dpi := 0
+		if widthPt > 0 {
+			dpi = int(math.Round(float64(img.Bounds().Dx()) * 72 / widthPt))
+		}
+
+		result = append(result, Page{
+			Image:  img,
+			DPI:    dpi,
+			Source: fmt.Sprintf("%s page %d", path, i+1),
+
+			// Pages shown rotated are scanned sideways
+			Orientation: rotationOrientations[page.rotate],
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,216 @@
+// Package scan loads scanned template pages from image files, multi-page
+// TIFFs and scanner PDFs, turned upright and with their recorded resolution.
+package scan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"strconv"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+
+	"glyph_extractor/imaging"
+)
+
+// Page is one scanned template page ready for extraction
+type Page struct {
+	Image  image.Image
+	DPI    int    // Resolution recorded in the file, 0 if unknown
+	Source string // File name, with the page number for multi-page files
+	Path   string // File the page was loaded from
+	Number int    // Page within the file, from 1
+
+	// Orientation recorded in the file as a TIFF/EXIF value (1-8, 0 if none).
+	// Load has already turned the image upright.
+	Orientation int
+}
+
+// MinDPI is the lowest recorded resolution trusted as a real scan
+// resolution; photos carry placeholder values like 72
+const MinDPI = 100
+
+// OrientationAuto turns pages upright using the orientation recorded in the
+// file instead of a fixed rotation
+const OrientationAuto = -1
+
+// ParseOrientation parses an orientation flag: auto, or a clockwise rotation
+// of 0, 90, 180 or 270 degrees that replaces the recorded orientation
+func ParseOrientation(s string) (int, error) {
+	switch s {
+	case "auto":
+		return OrientationAuto, nil
+	case "0", "90", "180", "270":
+		return strconv.Atoi(s)
+	}
+	return 0, fmt.Errorf("unknown orientation %q (want auto, 0, 90, 180 or 270)", s)
+}
+
+// Load loads every page of a scan. Image files hold one page, PDFs and
+// multi-page TIFFs one page per page. The format is detected from the content.
+// rotation is OrientationAuto or a clockwise rotation overriding the file's.
+func Load(path string, rotation int) ([]Page, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []Page
+	switch _, tiff := tiffByteOrder(data); {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		pages, err = loadPDFPages(path)
+	case tiff:
+		pages, err = loadTIFFPages(path, data)
+	default:
+		var img image.Image
+		img, _, err = image.Decode(bytes.NewReader(data))
+		dpi, orientation := imageMetadata(data)
+		pages = []Page{{Image: img, DPI: dpi, Source: path, Orientation: orientation}}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pages {
+		pages[i].Path, pages[i].Number = path, i+1
+		if rotation == OrientationAuto {
+			pages[i].Image = imaging.Orient(pages[i].Image, pages[i].Orientation)
+		} else {
+			pages[i].Image = imaging.Rotate(pages[i].Image, rotation)
+		}
+	}
+	return pages, nil
+}
+
+// LoadAll loads the pages of several scan files in order
+func LoadAll(paths []string, rotation int) ([]Page, error) {
+	var pages []Page
+	for _, path := range paths {
+		p, err := Load(path, rotation)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		pages = append(pages, p...)
+	}
+	return pages, nil
+}
+
+// imageMetadata reads the resolution and orientation stored in a PNG (pHYs
+// or eXIf), JPEG (JFIF or EXIF), WebP (EXIF) or BMP header. Missing values
+// are 0.
+func imageMetadata(data []byte) (dpi, orientation int) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngMetadata(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return jpegMetadata(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return webpMetadata(data)
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) >= 46:
+		// BITMAPINFOHEADER and later store pixels per metre
+		return metresToDPI(binary.LittleEndian.Uint32(data[38:])), 0
+	}
+	return 0, 0
+}
+
+// pngMetadata reads the density of a PNG's pHYs chunk and the orientation
+// and resolution of its eXIf chunk; the pHYs density wins. Some writers put
+// eXIf after the image data, so the whole file is searched.
+func pngMetadata(data []byte) (dpi, orientation int) {
+	exifDPI := 0
+	for pos := 8; pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		kind := string(data[pos+4 : pos+8])
+		body := pos + 8
+		if kind == "IEND" || length < 0 || body+length > len(data) {
+			break
+		}
+		switch {
+		case kind == "pHYs" && length >= 9 && data[body+8] == 1:
+			// Unit 1 is metres; unit 0 only gives the aspect ratio
+			dpi = metresToDPI(binary.BigEndian.Uint32(data[body:]))
+		case kind == "eXIf":
+			exifDPI, orientation = exifMetadata(data[body : body+length])
+		}
+		pos = body + length + 4 // Skip the CRC
+	}
+
+	if dpi == 0 {
+		dpi = exifDPI
+	}
+	return dpi, orientation
+}
+
+// webpMetadata reads the orientation and resolution of a WebP's EXIF chunk
+func webpMetadata(data []byte) (dpi, orientation int) {
+	for pos := 12; pos+8 <= len(data); {
+		kind := string(data[pos : pos+4])
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		body := pos + 8
+		if length < 0 || body+length > len(data) {
+			break
+		}
+		if kind == "EXIF" {
+			return exifMetadata(data[body : body+length])
+		}
+		pos = body + length + length%2 // Chunks are padded to an even size
+	}
+	return 0, 0
+}
+
+// exifMetadata reads the resolution and orientation of an EXIF payload, a
+// TIFF structure whose IFD0 describes the image. The "Exif" header JPEG
+// requires is optional elsewhere.
+func exifMetadata(payload []byte) (dpi, orientation int) {
+	payload = bytes.TrimPrefix(payload, []byte("Exif\x00\x00"))
+	ifds, err := readTIFFIFDs(payload)
+	if err != nil || len(ifds) == 0 {
+		return 0, 0
+	}
+	return ifds[0].DPI(), ifds[0].Orientation()
+}
+
+// jpegMetadata reads the density of a JPEG's JFIF segment and the
+// orientation and resolution of its EXIF segment. Phones usually write only
+// EXIF; when both are present the JFIF density wins.
+func jpegMetadata(data []byte) (dpi, orientation int) {
+	exifDPI := 0
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		body := pos + 4
+		end := body + length - 2
+		if marker == 0xDA || length < 2 || end > len(data) {
+			break // Start of scan: no more headers
+		}
+		segment := data[body:end]
+
+		switch {
+		case marker == 0xE0 && len(segment) >= 12 && bytes.HasPrefix(segment, []byte("JFIF\x00")):
+			density := float64(binary.BigEndian.Uint16(segment[8:]))
+			switch segment[7] {
+			case 1:
+				dpi = int(density + 0.5)
+			case 2:
+				dpi = int(density*2.54 + 0.5)
+			}
+		case marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			exifDPI, orientation = exifMetadata(segment)
+		}
+		pos = end
+	}
+
+	if dpi == 0 {
+		dpi = exifDPI
+	}
+	return dpi, orientation
+}
+
+// metresToDPI converts a resolution in pixels per metre to dots per inch
+func metresToDPI(ppm uint32) int {
+	return int(float64(ppm)*0.0254 + 0.5)
+}
@@ -0,0 +1,235 @@
+package scan
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/image/tiff"
+)
+
+// TIFF tags read directly from the image file directories
+const (
+	tagNewSubfileType = 254
+	tagOrientation    = 274
+	tagXResolution    = 282
+	tagResolutionUnit = 296
+)
+
+// tiffEntry is one field of an image file directory
+type tiffEntry struct {
+	Type  uint16
+	Count uint32
+	Data  []byte // Value bytes, already resolved from the offset if not inline
+}
+
+// tiffIFD is an image file directory: the tags of one TIFF page
+type tiffIFD struct {
+	Offset  uint32
+	Entries map[uint16]tiffEntry
+	order   binary.ByteOrder
+}
+
+// tiffTypeSizes is the byte size of one value of each TIFF field type
+var tiffTypeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// tiffByteOrder checks the TIFF header and returns its byte order
+func tiffByteOrder(data []byte) (binary.ByteOrder, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	switch string(data[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian, true
+	case "MM\x00*":
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+// readTIFFIFDs follows the chain of image file directories of a TIFF
+// structure. EXIF data uses the same layout, so it is read with this too.
+func readTIFFIFDs(data []byte) ([]tiffIFD, error) {
+	order, ok := tiffByteOrder(data)
+	if !ok {
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+
+	var ifds []tiffIFD
+	seen := make(map[uint32]bool)
+	offset := order.Uint32(data[4:8])
+	for offset != 0 {
+		if seen[offset] {
+			return nil, fmt.Errorf("TIFF directories loop")
+		}
+		seen[offset] = true
+
+		ifd, next, err := readTIFFIFD(data, order, offset)
+		if err != nil {
+			return nil, err
+		}
+		ifds = append(ifds, ifd)
+		offset = next
+	}
+	return ifds, nil
+}
+
+// readTIFFIFD reads the directory at offset and returns it with the offset of
+// the next one
+func readTIFFIFD(data []byte, order binary.ByteOrder, offset uint32) (tiffIFD, uint32, error) {
+	if int64(offset)+2 > int64(len(data)) {
+		return tiffIFD{}, 0, fmt.Errorf("TIFF directory offset %d out of range", offset)
+	}
+	count := uint32(order.Uint16(data[offset:]))
+	end := int64(offset) + 2 + int64(count)*12 + 4
+	if end > int64(len(data)) {
+		return tiffIFD{}, 0, fmt.Errorf("TIFF directory at %d is truncated", offset)
+	}
+
+	ifd := tiffIFD{Offset: offset, Entries: make(map[uint16]tiffEntry), order: order}
+	for i := uint32(0); i < count; i++ {
+		field := data[offset+2+i*12:]
+		entry := tiffEntry{Type: order.Uint16(field[2:]), Count: order.Uint32(field[4:])}
+
+		// Values up to 4 bytes are stored inline, longer ones at an offset
+		size := int64(tiffTypeSizes[entry.Type]) * int64(entry.Count)
+		if size <= 4 {
+			entry.Data = field[8 : 8+size]
+		} else if at := int64(order.Uint32(field[8:])); at+size <= int64(len(data)) {
+			entry.Data = data[at : at+size]
+		} else {
+			continue
+		}
+		ifd.Entries[order.Uint16(field)] = entry
+	}
+	return ifd, order.Uint32(data[end-4:]), nil
+}
+
+// Uint returns the first value of an integer tag
+func (ifd tiffIFD) Uint(tag uint16) (uint32, bool) {
+	e, ok := ifd.Entries[tag]
+	if !ok || e.Count == 0 {
+		return 0, false
+	}
+	switch e.Type {
+	case 1, 7:
+		return uint32(e.Data[0]), true
+	case 3:
+		return uint32(ifd.order.Uint16(e.Data)), true
+	case 4:
+		return ifd.order.Uint32(e.Data), true
+	}
+	return 0, false
+}
+
+// Rational returns the first value of a RATIONAL tag
+func (ifd tiffIFD) Rational(tag uint16) (float64, bool) {
+	e, ok := ifd.Entries[tag]
+	if !ok || e.Count == 0 || e.Type != 5 {
+		return 0, false
+	}
+	num, den := ifd.order.Uint32(e.Data), ifd.order.Uint32(e.Data[4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// DPI returns the horizontal resolution of the page in dots per inch,
+// 0 if the directory doesn't record one
+func (ifd tiffIFD) DPI() int {
+	res, ok := ifd.Rational(tagXResolution)
+	if !ok {
+		return 0
+	}
+	unit, ok := ifd.Uint(tagResolutionUnit)
+	if !ok {
+		unit = 2 // Inches is the TIFF default
+	}
+	switch unit {
+	case 2:
+		return int(res + 0.5)
+	case 3:
+		return int(res*2.54 + 0.5)
+	}
+	return 0
+}
+
+// Orientation returns the orientation tag (1-8), 1 if absent
+func (ifd tiffIFD) Orientation() int {
+	if o, ok := ifd.Uint(tagOrientation); ok && o >= 1 && o <= 8 {
+		return int(o)
+	}
+	return 1
+}
+
+// tiffPageReader presents a TIFF file whose header points at another image
+// file directory. The tiff package only decodes the first page, so every
+// page is decoded by making it the first.
+type tiffPageReader struct {
+	data   []byte
+	header [8]byte
+}
+
+// newTIFFPageReader makes the directory at ifdOffset the first page of data
+func newTIFFPageReader(data []byte, order binary.ByteOrder, ifdOffset uint32) *tiffPageReader {
+	r := &tiffPageReader{data: data}
+	copy(r.header[:], data[:8])
+	order.PutUint32(r.header[4:], ifdOffset)
+	return r
+}
+
+// ReadAt reads from the file with the patched header
+func (r *tiffPageReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[off:])
+	if off < int64(len(r.header)) {
+		copy(p, r.header[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read is never used by the decoder, which prefers ReadAt
+func (r *tiffPageReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("tiffPageReader only supports ReadAt")
+}
+
+// loadTIFFPages decodes every page of a (multi-page) TIFF scan with the
+// orientation and resolution each page records
+func loadTIFFPages(path string, data []byte) ([]Page, error) {
+	ifds, err := readTIFFIFDs(data)
+	if err != nil {
+		return nil, err
+	}
+	order, _ := tiffByteOrder(data)
+
+	var pages []Page
+	for _, ifd := range ifds {
+		// Skip reduced-resolution thumbnails (NewSubfileType bit 0)
+		if kind, ok := ifd.Uint(tagNewSubfileType); ok && kind&1 != 0 {
+			continue
+		}
+		// Pages are numbered as Load returns them, thumbnails left out
+		number := len(pages) + 1
+		img, err := tiff.Decode(newTIFFPageReader(data, order, ifd.Offset))
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", number, err)
+		}
+		source := path
+		if len(ifds) > 1 {
+			source = fmt.Sprintf("%s page %d", path, number)
+		}
+		pages = append(pages, Page{Image: img, DPI: ifd.DPI(), Source: source, Orientation: ifd.Orientation()})
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("TIFF has no pages")
+	}
+	return pages, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// StyleParams controls spacing and the natural variation of rendered handwriting.
+// Mirrors StyleParams in the Flutter app (lib/models/style_params.dart).
+type StyleParams struct {
+	LetterSpacing    float64 // Base spacing between letters in pixels
+	WordSpacing      float64 // Space width as multiplier of the average glyph width
+	LineHeight       float64 // Line height as multiplier of the cell height
+	BaselineWobble   float64 // How much the baseline wobbles (0-1)
+	SpacingVariance  float64 // How much the space between letters varies (0-1)
+	SizeVariance     float64 // Size variance (0-1)
+	RotationVariance float64 // Rotation variance (0-1)
+	OpacityVariance  float64 // Opacity variance for ink pressure simulation (0-1)
+}
+
+// Variation amplitudes at a variance of 1.0. Offsets are relative to the line
+// box so the look doesn't depend on the scan DPI.
+const (
+	maxBaselineOffset = 0.05 // Fraction of the line box height
+	maxKerningAdjust  = 0.02 // Fraction of the line box height
+	maxRotationDeg    = 3.0  // Degrees
+	maxScaleDelta     = 0.1  // Fraction of the glyph size
+)
+
+// DefaultStyleParams returns the default style
+func DefaultStyleParams() StyleParams {
+	return StyleParams{
+		LetterSpacing:    0,
+		WordSpacing:      1.0,
+		LineHeight:       1.2,
+		BaselineWobble:   0.3,
+		SpacingVariance:  0.3,
+		SizeVariance:     0.1,
+		RotationVariance: 0.2,
+		OpacityVariance:  0.1,
+	}
+}
+
+// NeatStyle returns neat, careful handwriting
+func NeatStyle() StyleParams {
+	return StyleParams{
+		LetterSpacing:    2,
+		WordSpacing:      1.2,
+		LineHeight:       1.3,
+		BaselineWobble:   0.1,
+		SpacingVariance:  0.2,
+		SizeVariance:     0.05,
+		RotationVariance: 0.1,
+		OpacityVariance:  0.05,
+	}
+}
+
+// CasualStyle returns casual, everyday handwriting
+func CasualStyle() StyleParams {
+	return StyleParams{
+		LetterSpacing:    0,
+		WordSpacing:      1.0,
+		LineHeight:       1.2,
+		BaselineWobble:   0.3,
+		SpacingVariance:  0.3,
+		SizeVariance:     0.15,
+		RotationVariance: 0.3,
+		OpacityVariance:  0.1,
+	}
+}
+
+// ChaoticStyle returns chaotic, rushed handwriting
+func ChaoticStyle() StyleParams {
+	return StyleParams{
+		LetterSpacing:    -2,
+		WordSpacing:      0.8,
+		LineHeight:       1.1,
+		BaselineWobble:   0.7,
+		SpacingVariance:  0.7,
+		SizeVariance:     0.3,
+		RotationVariance: 0.6,
+		OpacityVariance:  0.2,
+	}
+}
+
+// FastStyle returns fast, quick notes style
+func FastStyle() StyleParams {
+	return StyleParams{
+		LetterSpacing:    4,
+		WordSpacing:      1.1,
+		LineHeight:       1.15,
+		BaselineWobble:   0.5,
+		SpacingVariance:  0.5,
+		SizeVariance:     0.2,
+		RotationVariance: 0.4,
+		OpacityVariance:  0.15,
+	}
+}
+
+// StylePresets maps preset names to their styles
+var StylePresets = map[string]func() StyleParams{
+	"default": DefaultStyleParams,
+	"neat":    NeatStyle,
+	"casual":  CasualStyle,
+	"chaotic": ChaoticStyle,
+	"fast":    FastStyle,
+}
+
+// StylePreset looks up a preset by name
+func StylePreset(name string) (StyleParams, error) {
+	preset, ok := StylePresets[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(StylePresets))
+		for n := range StylePresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return StyleParams{}, fmt.Errorf("unknown style %q (want %s)", name, strings.Join(names, ", "))
+	}
+	return preset(), nil
+}
+
+// GlyphParams are the randomized parameters for one rendered glyph instance
+type GlyphParams struct {
+	BaselineOffset float64 // Vertical offset from the baseline in scan pixels
+	KerningAdjust  float64 // Horizontal adjustment of the advance in scan pixels
+	Rotation       float64 // Rotation in degrees
+	Scale          float64 // Scale factor
+	Opacity        float64 // Ink opacity (0-1)
+}
+
+// Variation generates per-glyph parameters from a seeded random source, so the
+// same seed and text always produce the same sticker
+type Variation struct {
+	style   StyleParams
+	lineBox float64
+	rng     *rand.Rand
+}
+
+// NewVariation creates a variation engine for the given style and seed
+func NewVariation(style StyleParams, lineBox int, seed int64) *Variation {
+	return &Variation{
+		style:   style,
+		lineBox: float64(lineBox),
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// randomRange returns a random value in [min, max)
+func (v *Variation) randomRange(min, max float64) float64 {
+	return min + v.rng.Float64()*(max-min)
+}
+
+// Next returns the parameters for the next glyph. The random values are
+// always drawn in the same order so a seed stays reproducible.
+func (v *Variation) Next() GlyphParams {
+	baseline := v.randomRange(-1, 1)
+	kerning := v.randomRange(-1, 1)
+	rotation := v.randomRange(-1, 1)
+	scale := v.randomRange(-1, 1)
+	opacity := v.rng.Float64()
+
+	return GlyphParams{
+		BaselineOffset: baseline * v.style.BaselineWobble * maxBaselineOffset * v.lineBox,
+		KerningAdjust:  kerning * v.style.SpacingVariance * maxKerningAdjust * v.lineBox,
+		Rotation:       rotation * v.style.RotationVariance * maxRotationDeg,
+		Scale:          1 + scale*v.style.SizeVariance*maxScaleDelta,
+		Opacity:        1 - opacity*v.style.OpacityVariance,
+	}
+}
+
+// TextSeed derives a seed from the text, matching the Flutter app's habit of
+// seeding with the text so the same text always looks the same
+func TextSeed(text string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return int64(h.Sum64() & (1<<63 - 1))
+}
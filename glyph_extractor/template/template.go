@@ -0,0 +1,208 @@
+// Package template generates the printable handwriting template PDFs: the
+// charset sheets and the kerning pairs sheet.
+package template
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"glyph_extractor/charset"
+	"glyph_extractor/layout"
+)
+
+// Config holds configuration for the template
+type Config struct {
+	CellWidthMM  float64     // 22.5 mm
+	CellHeightMM float64     // 26.2 mm
+	Columns      int         // 8
+	Rows         int         // 10
+	MarginTopMM  float64     // Top margin
+	MarginLeftMM float64     // Left margin
+	FontSize     float64     // Font size for labels
+	GridColor    color.NRGBA // Cell borders
+	GuideColor   color.NRGBA // Baseline guide
+	LabelColor   color.NRGBA // Character labels
+	Dropout      bool        // Guides are printed in a dropout colour
+}
+
+// Sheet selects which sheet the template generator prints
+type Sheet int
+
+const (
+	CharsetSheet Sheet = iota // One cell per Charset character
+	PairsSheet                // Wide cells with the kerning practice pairs
+)
+
+// DefaultConfig returns the layout of the charset sheets
+func DefaultConfig() Config {
+	return Config{
+		CellWidthMM:  22.5,
+		CellHeightMM: 26.2,
+		Columns:      8,
+		Rows:         10,
+		MarginTopMM:  15.0,
+		MarginLeftMM: 15.0,
+		FontSize:     8,
+		GridColor:    color.NRGBA{180, 180, 180, 255}, // Light gray
+		GuideColor:   color.NRGBA{200, 200, 255, 255}, // Light blue
+		LabelColor:   color.NRGBA{150, 150, 150, 255}, // Gray
+	}
+}
+
+// SetDropoutColor prints the grid, baseline and labels in one colour that the
+// extractor removes again with -dropout
+func (c *Config) SetDropoutColor(dropout color.NRGBA) {
+	c.GridColor = dropout
+	c.GuideColor = dropout
+	c.LabelColor = dropout
+	c.Dropout = true
+}
+
+// DefaultPairsConfig returns the layout of the kerning pairs sheet —
+// cells twice as wide so a pair can be written at its natural spacing
+func DefaultPairsConfig() Config {
+	config := DefaultConfig()
+	config.CellWidthMM = 45.0
+	config.Columns = 4
+	return config
+}
+
+// Generate writes the template PDF. A non-nil dropout colour prints
+// all guides in that colour.
+func Generate(outputPath string, sheet Sheet, dropout *color.NRGBA) error {
+	config := DefaultConfig()
+	if sheet == PairsSheet {
+		config = DefaultPairsConfig()
+	}
+	if dropout != nil {
+		config.SetDropoutColor(*dropout)
+	}
+
+	// Create PDF (A4: 210 x 297 mm)
+	pdf := gofpdf.New("P", "mm", "A4", "")
+
+	// Add UTF-8 font for full Unicode support
+	// Note: gofpdf has issues with absolute paths, using relative path from cwd
+	pdf.AddUTF8Font("DejaVu", "", "fonts/DejaVuSans.ttf")
+	pdf.AddUTF8Font("DejaVu", "B", "fonts/DejaVuSans.ttf")
+	pdf.AddUTF8Font("DejaVu", "I", "fonts/DejaVuSans.ttf")
+
+	if sheet == PairsSheet {
+		perPage := config.Columns * config.Rows
+		for page, start := 1, 0; start < len(charset.KerningPairs); page, start = page+1, start+perPage {
+			end := min(start+perPage, len(charset.KerningPairs))
+			pdf.AddPage()
+			drawGrid(pdf, config, charset.KerningPairs[start:end], fmt.Sprintf("Dvojice %d - Napište obě písmena vedle sebe", page))
+		}
+		return pdf.OutputFileAndClose(outputPath)
+	}
+
+	// Page 1
+	pdf.AddPage()
+	drawGrid(pdf, config, charset.Charset[:80], "Strana 1 - Velká písmena, čísla, interpunkce")
+
+	// Page 2
+	pdf.AddPage()
+	drawGrid(pdf, config, charset.Charset[80:160], "Strana 2 - Malá písmena, speciální znaky")
+
+	// Page 3
+	pdf.AddPage()
+	drawGrid(pdf, config, charset.Charset[160:], "Strana 3 - Ligatury a tvary na začátku a konci slova")
+
+	return pdf.OutputFileAndClose(outputPath)
+}
+
+// positionLabels describe positional form cells on the template
+var positionLabels = map[string]string{
+	charset.PositionInitial:  "(začátek)",
+	charset.PositionMedial:   "(uprostřed)",
+	charset.PositionFinal:    "(konec)",
+	charset.PositionIsolated: "(samotné)",
+}
+
+func drawGrid(pdf *gofpdf.Fpdf, config Config, chars []string, title string) {
+	// Title
+	pdf.SetFont("DejaVu", "B", 12)
+	pdf.SetXY(config.MarginLeftMM, 5)
+	pdf.Cell(0, 10, title)
+
+	// Set up for grid
+	pdf.SetFont("DejaVu", "", config.FontSize)
+	pdf.SetDrawColor(int(config.GridColor.R), int(config.GridColor.G), int(config.GridColor.B))
+	pdf.SetLineWidth(0.3)
+
+	charIndex := 0
+
+	for row := 0; row < config.Rows; row++ {
+		for col := 0; col < config.Columns; col++ {
+			x := config.MarginLeftMM + float64(col)*config.CellWidthMM
+			y := config.MarginTopMM + float64(row)*config.CellHeightMM
+
+			// Draw cell border
+			pdf.Rect(x, y, config.CellWidthMM, config.CellHeightMM, "D")
+
+			// Draw character label in top-left corner
+			if charIndex < len(chars) {
+				char := chars[charIndex]
+				label := char
+
+				// Handle special characters for display
+				switch char {
+				case " ":
+					label = "SP"
+				case "\t":
+					label = "TAB"
+				case "\n":
+					label = "NL"
+				default:
+					if base, position := charset.SplitPosition(char); position != "" {
+						label = base + " " + positionLabels[position]
+					}
+				}
+
+				pdf.SetTextColor(int(config.LabelColor.R), int(config.LabelColor.G), int(config.LabelColor.B))
+				pdf.SetXY(x+1, y+1)
+				pdf.Cell(0, 0, label)
+				pdf.SetTextColor(0, 0, 0) // Reset to black
+
+				charIndex++
+			}
+		}
+	}
+
+	// Draw baseline guide (dashed line at ~70% height of each cell)
+	pdf.SetDrawColor(int(config.GuideColor.R), int(config.GuideColor.G), int(config.GuideColor.B))
+	pdf.SetLineWidth(0.2)
+
+	baselineOffset := config.CellHeightMM * layout.BaselineRatio
+
+	for row := 0; row < config.Rows; row++ {
+		y := config.MarginTopMM + float64(row)*config.CellHeightMM + baselineOffset
+		x1 := config.MarginLeftMM
+		x2 := config.MarginLeftMM + float64(config.Columns)*config.CellWidthMM
+
+		// Draw dashed line
+		dashLen := 2.0
+		gapLen := 1.0
+		for x := x1; x < x2; x += dashLen + gapLen {
+			endX := x + dashLen
+			if endX > x2 {
+				endX = x2
+			}
+			pdf.Line(x, y, endX, y)
+		}
+	}
+
+	// Footer with info
+	pdf.SetFont("DejaVu", "I", 8)
+	pdf.SetTextColor(128, 128, 128)
+	pdf.SetXY(config.MarginLeftMM, 285)
+	guide := "Modrá čára = účaří"
+	if config.Dropout {
+		guide = "Čárkovaná čára = účaří"
+	}
+	pdf.Cell(0, 0, fmt.Sprintf("Políčko: %.1f × %.1f mm | Mřížka: %d × %d | %s",
+		config.CellWidthMM, config.CellHeightMM, config.Columns, config.Rows, guide))
+}